@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -248,3 +249,29 @@ func TestSliceIndexIterator(t *testing.T) {
 		})
 	}
 }
+
+func TestGrpcHandler_HandleCallback(t *testing.T) {
+	inputData := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	indexIterator := NewSegmentIterator(0, len(inputData), 3)
+
+	var batchSizes []int
+	serialBatch := NewGrpcHandler(
+		doVeryLongCalculation,
+		func() (req []int, ok bool) {
+			from, to, ok := indexIterator.Next()
+			if !ok {
+				return nil, false
+			}
+			return inputData[from:to], true
+		},
+		WithHandleCallback(func(req []int, dur time.Duration, err error) {
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, dur, time.Duration(0))
+			batchSizes = append(batchSizes, len(req))
+		}),
+	)
+
+	_, err := serialBatch.DoSerial(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 3, 3, 1}, batchSizes)
+}
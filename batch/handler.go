@@ -3,6 +3,7 @@ package batch
 import (
 	"context"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
@@ -16,16 +17,24 @@ type GrpcHandler[RespT, ReqT any] struct {
 	// если следующего нет, то возвращает (nil, false)
 	// внимание итератор должен быть потоко-безопасен
 	next func() (ReqT, bool)
+	// handleCallback функция вызываемая после обработки каждого батча
+	handleCallback HandleCallback[ReqT]
 }
 
 // NewGrpcHandler конструктор batch обертки grpc ручки
 func NewGrpcHandler[RespT, ReqT any](
 	handle func(context.Context, ReqT, ...grpc.CallOption) (RespT, error),
 	next func() (ReqT, bool),
+	opts ...Option[ReqT],
 ) *GrpcHandler[RespT, ReqT] {
+	config := newDefaultOptions[ReqT]()
+	for _, opt := range opts {
+		opt(config)
+	}
 	return &GrpcHandler[RespT, ReqT]{
-		handle: handle,
-		next:   next,
+		handle:         handle,
+		next:           next,
+		handleCallback: config.handleCallback,
 	}
 }
 
@@ -58,7 +67,7 @@ func (h *GrpcHandler[RespT, ReqT]) DoConcurrent(
 					break
 				}
 
-				response, err := h.handle(gCtx, req)
+				response, err := h.handleBatch(gCtx, req)
 				if err != nil {
 					return err
 				}
@@ -91,7 +100,7 @@ func (h *GrpcHandler[RespT, ReqT]) DoSerial(ctx context.Context) ([]RespT, error
 			break
 		}
 
-		response, err := h.handle(ctx, req)
+		response, err := h.handleBatch(ctx, req)
 		if err != nil {
 			return nil, err
 		}
@@ -99,3 +108,11 @@ func (h *GrpcHandler[RespT, ReqT]) DoSerial(ctx context.Context) ([]RespT, error
 	}
 	return retRespSeries, nil
 }
+
+// handleBatch обработка одного батча
+func (h *GrpcHandler[RespT, ReqT]) handleBatch(ctx context.Context, req ReqT) (RespT, error) {
+	start := time.Now()
+	response, err := h.handle(ctx, req)
+	h.handleCallback(req, time.Since(start), err)
+	return response, err
+}
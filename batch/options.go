@@ -0,0 +1,34 @@
+package batch
+
+import "time"
+
+// HandleCallback функция которая вызывается после обработки каждого батча,
+// получает запрос батча, длительность его обработки и ошибку
+type HandleCallback[ReqT any] func(req ReqT, dur time.Duration, err error)
+
+// Option функция для изменения настроек GrpcHandler-а
+type Option[ReqT any] func(o *options[ReqT])
+
+type options[ReqT any] struct {
+	handleCallback HandleCallback[ReqT]
+}
+
+// newDefaultOptions конструктор настроек по умолчанию
+func newDefaultOptions[ReqT any]() *options[ReqT] {
+	return &options[ReqT]{
+		handleCallback: func(_ ReqT, _ time.Duration, _ error) {},
+	}
+}
+
+// WithHandleCallback настройка функции callback вызываемой после обработки
+// каждого батча (обычно используется для логов и метрик). Добавляется
+// к заданным ранее callback-ам и вызывается после них
+func WithHandleCallback[ReqT any](callback HandleCallback[ReqT]) Option[ReqT] {
+	return func(o *options[ReqT]) {
+		prev := o.handleCallback
+		o.handleCallback = func(req ReqT, dur time.Duration, err error) {
+			prev(req, dur, err)
+			callback(req, dur, err)
+		}
+	}
+}
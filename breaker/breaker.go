@@ -3,65 +3,99 @@ package breaker
 import (
 	"context"
 	"errors"
-	"sync"
 	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
 )
 
 // Breaker is a simple implementation of a Circuit breaker design pattern
 //
 // It is used to detect failures and encapsulates the logic of
-// preventing a failure
+// preventing a failure.
+//
+// The circuit opens after more than failureThreshold consecutive failures
+// or, if WithFailureRateThreshold is set, when the failure rate in the sliding
+// window reaches the threshold. Calls slower than WithSlowCallThreshold are
+// treated as failures, or trip the circuit on their own if WithSlowCallRateThreshold
+// is set; their results are returned to the caller unchanged. Errors rejected
+// by the failure classifier (see DefaultFailureClassifier) are ignored.
+// Once breakDelay has elapsed it becomes half-open and lets through at most
+// halfOpenMaxProbes probe requests: halfOpenSuccessThreshold consecutive
+// successful probes close the circuit, any failed probe opens it again.
+// Repeated re-opening may grow the break delay (see WithBreakDelayBackoff)
+// until the circuit closes. To serve a fallback response instead of
+// the rejection see WrapWithFallback.
 func Breaker[RespT, ReqT any](
 	handler func(context.Context, ReqT) (RespT, error),
 	opts ...Option,
 ) func(context.Context, ReqT) (RespT, error) {
-	config := newDefaultOptions()
-	for _, opt := range opts {
-		opt(config)
-	}
-	var (
-		lastAttempt         = time.Now()
-		consecutiveFailures = 0
-		m                   sync.RWMutex
-	)
-	return func(ctx context.Context, req ReqT) (RespT, error) {
-		m.RLock()
-
-		failures := consecutiveFailures - int(config.failureThreshold)
-		if failures > 0 {
-			shouldRetryAt := lastAttempt.Add(config.breakDelay)
-			if !time.Now().After(shouldRetryAt) {
-				m.RUnlock()
-				config.breakCallback(failures, lastAttempt, shouldRetryAt)
-				var nilResp RespT
-				return nilResp, config.unreachableError
-			}
-		}
+	return Wrap(New(opts...), handler)
+}
 
-		m.RUnlock()
+// Wrap wraps handler with an existing CircuitBreaker, so the circuit
+// can be inspected and controlled while the handler is in use
+func Wrap[RespT, ReqT any](
+	cb *CircuitBreaker,
+	handler func(context.Context, ReqT) (RespT, error),
+) func(context.Context, ReqT) (RespT, error) {
+	return wrap(cb, handler, nil, false)
+}
 
-		resp, err := handler(ctx, req)
+// Fallback функция, ответ которой возвращается вместо ошибки вызова,
+// получает запрос и ошибку, с которой завершился бы вызов
+type Fallback[RespT, ReqT any] func(ctx context.Context, req ReqT, err error) (RespT, error)
 
-		m.Lock()
-		defer m.Unlock()
+// WrapWithFallback is like Wrap, but calls rejected by the circuit return
+// the fallback response (e.g. a cached or default one) instead of the
+// unreachable error
+func WrapWithFallback[RespT, ReqT any](
+	cb *CircuitBreaker,
+	handler func(context.Context, ReqT) (RespT, error),
+	fallback Fallback[RespT, ReqT],
+) func(context.Context, ReqT) (RespT, error) {
+	return wrap(cb, handler, fallback, false)
+}
 
-		lastAttempt = time.Now()
+// WrapWithFailureFallback is like WrapWithFallback, but also returns
+// the fallback response when handler fails with an error the failure
+// classifier counts as a failure
+func WrapWithFailureFallback[RespT, ReqT any](
+	cb *CircuitBreaker,
+	handler func(context.Context, ReqT) (RespT, error),
+	fallback Fallback[RespT, ReqT],
+) func(context.Context, ReqT) (RespT, error) {
+	return wrap(cb, handler, fallback, true)
+}
 
-		if err != nil {
-			consecutiveFailures++
+// wrap оборачивает handler цепью, fallback вызывается для отклоненных вызовов,
+// а если onFailure, то и для сбоев handler-а
+func wrap[RespT, ReqT any](
+	cb *CircuitBreaker,
+	handler func(context.Context, ReqT) (RespT, error),
+	fallback Fallback[RespT, ReqT],
+	onFailure bool,
+) func(context.Context, ReqT) (RespT, error) {
+	return func(ctx context.Context, req ReqT) (RespT, error) {
+		var (
+			resp   RespT
+			called bool
+		)
+		err := cb.Execute(ctx, func(ctx context.Context) error {
+			var err error
+			called = true
+			resp, err = handler(ctx, req)
+			return err
+		})
+		if err == nil || fallback == nil {
 			return resp, err
 		}
-
-		consecutiveFailures = 0
-
+		if !called || onFailure && cb.config.failureClassifier(err) {
+			return fallback(ctx, req, err)
+		}
 		return resp, err
 	}
 }
 
-// Callback функция которая вызывается каждый раз после размыкания цепи
-// обычно используется для логов
-type Callback func(failures int, lastAttempt, shouldRetryAt time.Time)
-
 // Option функция для изменения настроек поведения Breaker-а
 type Option func(o *options)
 
@@ -69,15 +103,47 @@ type options struct {
 	failureThreshold uint
 	unreachableError error
 	breakDelay       time.Duration
-	breakCallback    Callback
+	rejectCallback   RejectCallback
+	clock            clock.Clock
+
+	stateChangeCallback StateChangeCallback
+	createCallback      func(State)
+
+	breakDelayFactor float64
+	maxBreakDelay    time.Duration
+	breakDelayJitter func(time.Duration) time.Duration
+
+	halfOpenMaxProbes        uint
+	halfOpenSuccessThreshold uint
+
+	failureRateThreshold float64
+	minimumRequests      uint
+	windowSize           uint
+	windowDuration       time.Duration
+
+	slowCallThreshold     time.Duration
+	slowCallRateThreshold float64
+
+	failureClassifier func(error) bool
 }
 
 // Настройки Breaker-а по умолчанию
 var (
-	defaultFailureThreshold uint  = 10
-	defaultUnreachableError error = errors.New("service is unreachble")
-	defaultBreakDelay             = 2 * time.Second
-	defaultBreakCallback          = func(_ int, _, _ time.Time) {}
+	defaultFailureThreshold    uint  = 10
+	defaultUnreachableError    error = errors.New("service is unreachble")
+	defaultBreakDelay                = 2 * time.Second
+	defaultRejectCallback            = func(_ State) {}
+	defaultStateChangeCallback       = func(_, _ State) {}
+	defaultCreateCallback            = func(_ State) {}
+	defaultBreakDelayJitter          = func(d time.Duration) time.Duration { return d }
+
+	defaultBreakDelayFactor float64 = 1
+
+	defaultHalfOpenMaxProbes        uint = 1
+	defaultHalfOpenSuccessThreshold uint = 1
+
+	defaultMinimumRequests uint = 10
+	defaultWindowSize      uint = 100
 )
 
 // newDefaultOptions конструктор настроек по умолчанию
@@ -86,8 +152,50 @@ func newDefaultOptions() *options {
 		failureThreshold: defaultFailureThreshold,
 		unreachableError: defaultUnreachableError,
 		breakDelay:       defaultBreakDelay,
-		breakCallback:    defaultBreakCallback,
+		rejectCallback:   defaultRejectCallback,
+		clock:            clock.New(),
+
+		stateChangeCallback: defaultStateChangeCallback,
+		createCallback:      defaultCreateCallback,
+
+		breakDelayFactor: defaultBreakDelayFactor,
+		breakDelayJitter: defaultBreakDelayJitter,
+
+		halfOpenMaxProbes:        defaultHalfOpenMaxProbes,
+		halfOpenSuccessThreshold: defaultHalfOpenSuccessThreshold,
+
+		minimumRequests: defaultMinimumRequests,
+		windowSize:      defaultWindowSize,
+
+		failureClassifier: DefaultFailureClassifier,
+	}
+}
+
+// outcome результат вызова с учетом настроек медленных вызовов
+func (o *options) outcome(failed bool, elapsed time.Duration) outcome {
+	slow := o.slowCallThreshold > 0 && elapsed >= o.slowCallThreshold
+	return outcome{
+		// без отдельного порога доли медленных вызовов медленный вызов считается ошибкой
+		failed: failed || slow && o.slowCallRateThreshold <= 0,
+		slow:   slow,
+	}
+}
+
+// nextBreakDelay время размыкания после повторного размыкания цепи
+func (o *options) nextBreakDelay(delay time.Duration) time.Duration {
+	next := time.Duration(float64(delay) * o.breakDelayFactor)
+	if o.maxBreakDelay > 0 && next > o.maxBreakDelay {
+		return o.maxBreakDelay
 	}
+	return next
+}
+
+// newWindow конструктор скользящего окна по настройкам
+func (o *options) newWindow() window {
+	if o.windowDuration > 0 {
+		return newTimeWindow(o.windowDuration)
+	}
+	return newCountWindow(o.windowSize)
 }
 
 // WithFailureThreshold настройка порога количества ошибок идущих подряд,
@@ -113,10 +221,143 @@ func WithBreakDelay(delay time.Duration) Option {
 	}
 }
 
-// WithBreakCallback настройка функции callback вызываемой после попытки запроса
-// при разомкнутой цепи
-func WithBreakCallback(callback Callback) Option {
+// WithBreakDelayBackoff настройка роста времени размыкания: каждый раз когда
+// полуоткрытая цепь снова размыкается, время размыкания умножается на factor,
+// но не превышает maxDelay (0 - без ограничения). После замыкания цепи время
+// размыкания возвращается к WithBreakDelay
+func WithBreakDelayBackoff(factor float64, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.breakDelayFactor = factor
+		o.maxBreakDelay = maxDelay
+	}
+}
+
+// WithBreakDelayJitter настройка случайного изменения времени размыкания
+// (например retry.FullJitter или retry.EqualJitter), что бы цепи разных
+// клиентов не переходили в полуоткрытое состояние одновременно
+func WithBreakDelayJitter(jitter func(time.Duration) time.Duration) Option {
+	return func(o *options) {
+		o.breakDelayJitter = jitter
+	}
+}
+
+// WithFailureRateThreshold включает размыкание цепи по доле ошибок (от 0 до 1)
+// в скользящем окне вместо ошибок идущих подряд. Цепь размыкается когда доля
+// ошибок достигает rate, если в окне не меньше WithMinimumRequests вызовов
+func WithFailureRateThreshold(rate float64) Option {
+	return func(o *options) {
+		o.failureRateThreshold = rate
+	}
+}
+
+// WithMinimumRequests настройка минимального количества вызовов в окне,
+// начиная с которого считается доля ошибок
+func WithMinimumRequests(minimumRequests uint) Option {
+	return func(o *options) {
+		o.minimumRequests = minimumRequests
+	}
+}
+
+// WithCountWindow настройка скользящего окна из size последних вызовов (по умолчанию)
+func WithCountWindow(size uint) Option {
+	return func(o *options) {
+		o.windowSize = size
+		o.windowDuration = 0
+	}
+}
+
+// WithTimeWindow настройка скользящего окна вызовов за последний интервал d
+// (округляется до секунд)
+func WithTimeWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.windowDuration = d
+	}
+}
+
+// WithFailureClassifier настройка классификатора ошибок: ошибка учитывается
+// как сбой только если classifier вернул true, остальные ошибки не влияют
+// на состояние цепи (по умолчанию DefaultFailureClassifier)
+func WithFailureClassifier(classifier func(error) bool) Option {
+	return func(o *options) {
+		o.failureClassifier = classifier
+	}
+}
+
+// WithSlowCallThreshold настройка длительности, начиная с которой вызов считается
+// медленным (0 - не учитывать длительность). Медленный вызов считается ошибкой,
+// если не задан WithSlowCallRateThreshold, а медленный пробный запрос в полуоткрытом
+// состоянии всегда размыкает цепь
+func WithSlowCallThreshold(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slowCallThreshold = threshold
+	}
+}
+
+// WithSlowCallRateThreshold настройка доли медленных вызовов (от 0 до 1)
+// в скользящем окне, при достижении которой цепь размыкается (если в окне
+// не меньше WithMinimumRequests вызовов)
+func WithSlowCallRateThreshold(rate float64) Option {
+	return func(o *options) {
+		o.slowCallRateThreshold = rate
+	}
+}
+
+// WithHalfOpenMaxProbes настройка количества пробных запросов, которые
+// одновременно пропускает полуоткрытая цепь (0 считается как 1)
+func WithHalfOpenMaxProbes(maxProbes uint) Option {
+	return func(o *options) {
+		o.halfOpenMaxProbes = maxProbes
+	}
+}
+
+// WithHalfOpenSuccessThreshold настройка количества успешных пробных запросов
+// подряд, после которых полуоткрытая цепь замыкается
+func WithHalfOpenSuccessThreshold(successThreshold uint) Option {
+	return func(o *options) {
+		o.halfOpenSuccessThreshold = successThreshold
+	}
+}
+
+// WithRejectCallback настройка функции callback вызываемой после попытки запроса
+// при разомкнутой цепи (для логов смены состояния используйте WithStateChangeCallback).
+// Как и остальные callback-и, добавляется к заданным ранее и вызывается после них
+func WithRejectCallback(callback RejectCallback) Option {
+	return func(o *options) {
+		prev := o.rejectCallback
+		o.rejectCallback = func(state State) {
+			prev(state)
+			callback(state)
+		}
+	}
+}
+
+// WithStateChangeCallback настройка функции callback вызываемой при смене
+// состояния цепи
+func WithStateChangeCallback(callback StateChangeCallback) Option {
+	return func(o *options) {
+		prev := o.stateChangeCallback
+		o.stateChangeCallback = func(from, to State) {
+			prev(from, to)
+			callback(from, to)
+		}
+	}
+}
+
+// WithCreateCallback настройка функции callback вызываемой при создании цепи
+// с ее начальным состоянием (например для начального значения метрик)
+func WithCreateCallback(callback func(state State)) Option {
+	return func(o *options) {
+		prev := o.createCallback
+		o.createCallback = func(state State) {
+			prev(state)
+			callback(state)
+		}
+	}
+}
+
+// WithClock настройка источника времени (используется в тестах)
+func WithClock(clk clock.Clock) Option {
 	return func(o *options) {
-		o.breakCallback = callback
+		o.clock = clk
 	}
 }
@@ -0,0 +1,503 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+var (
+	errHandler     = errors.New("handler")
+	errUnreachable = errors.New("unreachable")
+)
+
+// step шаг сценария работы Breaker-а
+type step struct {
+	advance    time.Duration
+	duration   time.Duration
+	handlerErr error
+	wantErr    error
+	wantCalled bool
+}
+
+// runSteps выполняет сценарий шагов на Breaker-е с ручными часами
+func runSteps(t *testing.T, opts []Option, steps []step) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var (
+		handlerErr error
+		called     bool
+		duration   time.Duration
+	)
+	handler := Breaker(
+		func(_ context.Context, req int) (int, error) {
+			called = true
+			fake.Advance(duration)
+			return req, handlerErr
+		},
+		append([]Option{WithClock(fake), WithUnreachableError(errUnreachable)}, opts...)...,
+	)
+
+	for i, s := range steps {
+		fake.Advance(s.advance)
+		handlerErr, duration, called = s.handlerErr, s.duration, false
+
+		resp, err := handler(context.Background(), i)
+		if s.wantCalled {
+			require.Equal(t, i, resp, "step %d", i)
+		}
+		require.ErrorIs(t, err, s.wantErr, "step %d", i)
+		if s.wantErr == nil {
+			require.NoError(t, err, "step %d", i)
+		}
+		require.Equal(t, s.wantCalled, called, "step %d", i)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name: "closed_on_success",
+			opts: []Option{WithFailureThreshold(1)},
+			steps: []step{
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+		{
+			name: "open_after_threshold",
+			opts: []Option{WithFailureThreshold(1), WithBreakDelay(time.Second)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: nil, wantErr: errUnreachable, wantCalled: false},
+				{advance: 500 * time.Millisecond, wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "success_resets_failures",
+			opts: []Option{WithFailureThreshold(1), WithBreakDelay(time.Second)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+		{
+			name: "retry_after_break_delay",
+			opts: []Option{WithFailureThreshold(0), WithBreakDelay(time.Second)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+				{advance: 2 * time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
+
+func TestBreakerStateChangeCallback(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var transitions []string
+	handlerErr := errHandler
+	handler := Breaker(
+		func(context.Context, int) (int, error) { return 0, handlerErr },
+		WithClock(fake),
+		WithFailureThreshold(1),
+		WithBreakDelay(time.Second),
+		WithStateChangeCallback(func(from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		}),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, _ = handler(context.Background(), i)
+	}
+	require.Equal(t, []string{"closed->open"}, transitions)
+
+	fake.Advance(2 * time.Second)
+	handlerErr = nil
+	_, err := handler(context.Background(), 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, transitions)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name: "probe_failure_reopens",
+			opts: []Option{WithFailureThreshold(0), WithBreakDelay(time.Second)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+			},
+		},
+		{
+			name: "several_successes_to_close",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithBreakDelay(time.Second),
+				WithHalfOpenSuccessThreshold(3),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				// цепь все еще полуоткрыта, ошибка снова ее размыкает
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				// цепь замкнута, порог ошибок снова применяется как обычно
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "zero_max_probes",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithBreakDelay(time.Second),
+				WithHalfOpenMaxProbes(0),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				// 0 пробных слотов считается как 1
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
+
+func TestBreakerHalfOpenMaxProbes(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	failing := true
+	handler := Breaker(
+		func(context.Context, int) (int, error) {
+			if failing {
+				return 0, errHandler
+			}
+			started <- struct{}{}
+			<-release
+			return 0, nil
+		},
+		WithClock(fake),
+		WithUnreachableError(errUnreachable),
+		WithFailureThreshold(0),
+		WithBreakDelay(time.Second),
+		WithHalfOpenMaxProbes(2),
+		WithHalfOpenSuccessThreshold(2),
+	)
+
+	_, _ = handler(context.Background(), 0)
+	fake.Advance(time.Second)
+	failing = false
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := handler(context.Background(), 0)
+			errs <- err
+		}()
+		<-started
+	}
+
+	// оба пробных слота заняты
+	_, err := handler(context.Background(), 0)
+	require.ErrorIs(t, err, errUnreachable)
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+}
+
+func TestBreakerBreakDelayBackoff(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name: "grows_until_cap_and_resets_on_close",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithBreakDelay(time.Second),
+				WithBreakDelayBackoff(2, 3*time.Second),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				// повторное размыкание на 2s
+				{advance: time.Second, handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, wantErr: errUnreachable, wantCalled: false},
+				// повторное размыкание ограничено 3s
+				{advance: time.Second, handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: 2 * time.Second, wantErr: errUnreachable, wantCalled: false},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+				// цепь замкнута, время размыкания снова 1s
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+		{
+			name: "jitter",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithBreakDelay(2 * time.Second),
+				WithBreakDelayJitter(func(d time.Duration) time.Duration { return d / 2 }),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, handlerErr: nil, wantErr: nil, wantCalled: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
+
+func TestBreakerFailureRate(t *testing.T) {
+	// 60% ошибок вперемешку с успехами, ошибок подряд не больше двух
+	alternating := []step{
+		{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+		{handlerErr: nil, wantErr: nil, wantCalled: true},
+		{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+		{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+		{handlerErr: nil, wantErr: nil, wantCalled: true},
+	}
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name:  "consecutive_never_trips",
+			opts:  []Option{WithFailureThreshold(2)},
+			steps: append(append([]step{}, alternating...), step{wantErr: nil, wantCalled: true}),
+		},
+		{
+			name: "count_window_trips",
+			opts: []Option{
+				WithFailureRateThreshold(0.5),
+				WithMinimumRequests(5),
+				WithCountWindow(10),
+			},
+			steps: append(append([]step{}, alternating...), step{wantErr: errUnreachable, wantCalled: false}),
+		},
+		{
+			name: "below_minimum_requests",
+			opts: []Option{
+				WithFailureRateThreshold(0.5),
+				WithMinimumRequests(6),
+				WithCountWindow(10),
+			},
+			steps: append(append([]step{}, alternating...), step{wantErr: nil, wantCalled: true}),
+		},
+		{
+			name: "count_window_slides",
+			opts: []Option{
+				WithFailureRateThreshold(0.5),
+				WithMinimumRequests(4),
+				WithCountWindow(4),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				// первая ошибка вытеснена, в окне 1 ошибка из 4
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+				// в окне 2 ошибки из 4
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "time_window_expires",
+			opts: []Option{
+				WithFailureRateThreshold(0.5),
+				WithMinimumRequests(2),
+				WithTimeWindow(10 * time.Second),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				// ошибка вышла из окна, в окне 1 вызов
+				{advance: 10 * time.Second, handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: nil, wantErr: nil, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
+
+func TestBreakerSlowCalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name: "slow_as_failure",
+			opts: []Option{WithFailureThreshold(1), WithSlowCallThreshold(time.Second)},
+			steps: []step{
+				{duration: time.Second, wantErr: nil, wantCalled: true},
+				{duration: 2 * time.Second, wantErr: nil, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "fast_resets_consecutive",
+			opts: []Option{WithFailureThreshold(1), WithSlowCallThreshold(time.Second)},
+			steps: []step{
+				{duration: time.Second, wantErr: nil, wantCalled: true},
+				{duration: time.Millisecond, wantErr: nil, wantCalled: true},
+				{duration: time.Second, wantErr: nil, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+			},
+		},
+		{
+			name: "slow_rate",
+			opts: []Option{
+				WithFailureThreshold(100),
+				WithSlowCallThreshold(time.Second),
+				WithSlowCallRateThreshold(0.5),
+				WithMinimumRequests(4),
+			},
+			steps: []step{
+				{duration: time.Second, wantErr: nil, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+				{duration: time.Second, wantErr: nil, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "slow_probe_reopens",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithBreakDelay(time.Minute),
+				WithSlowCallThreshold(time.Second),
+			},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Minute, duration: time.Second, wantErr: nil, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
+
+func TestWrapWithFallback(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	tests := []struct {
+		name       string
+		opts       []Option
+		wrap       func(*CircuitBreaker, func(context.Context, string) (string, error), Fallback[string, string]) func(context.Context, string) (string, error)
+		handlerErr []error
+		wantResp   []string
+		wantErr    []error
+	}{
+		{
+			name:       "on_reject",
+			opts:       []Option{WithFailureThreshold(0)},
+			wrap:       WrapWithFallback[string, string],
+			handlerErr: []error{errHandler, nil},
+			wantResp:   []string{"", "fallback: unreachable"},
+			wantErr:    []error{errHandler, nil},
+		},
+		{
+			name: "on_failure",
+			opts: []Option{
+				WithFailureThreshold(1),
+				WithFailureClassifier(func(err error) bool { return !errors.Is(err, errInvalid) }),
+			},
+			wrap:       WrapWithFailureFallback[string, string],
+			handlerErr: []error{errInvalid, errHandler, nil},
+			wantResp:   []string{"", "fallback: handler", "ok"},
+			wantErr:    []error{errInvalid, nil, nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var handlerErr error
+			handler := test.wrap(
+				New(append([]Option{
+					WithUnreachableError(errUnreachable),
+					WithBreakDelay(time.Hour),
+				}, test.opts...)...),
+				func(_ context.Context, req string) (string, error) {
+					if handlerErr != nil {
+						return "", handlerErr
+					}
+					return req, nil
+				},
+				func(_ context.Context, _ string, err error) (string, error) {
+					return "fallback: " + err.Error(), nil
+				},
+			)
+
+			for i := range test.handlerErr {
+				handlerErr = test.handlerErr[i]
+				resp, err := handler(context.Background(), "ok")
+				require.ErrorIs(t, err, test.wantErr[i], "call %d", i)
+				if test.wantErr[i] == nil {
+					require.NoError(t, err, "call %d", i)
+				}
+				require.Equal(t, test.wantResp[i], resp, "call %d", i)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker цепь circuit breaker-а с состоянием, которое можно посмотреть
+// и изменить вручную: closed -> open -> half-open -> closed/open.
+// Безопасен для конкурентного использования
+type CircuitBreaker struct {
+	config *options
+
+	mu sync.Mutex
+	// generation увеличивается при каждой смене состояния, результаты вызовов,
+	// начатых в предыдущем состоянии, игнорируются
+	generation uint64
+	state      State
+	// forced состояние задано вручную через ForceOpen или ForceClosed
+	forced bool
+	counts Counts
+	// window скользящее окно вызовов в замкнутом состоянии
+	window   window
+	openedAt time.Time
+	// breakDelay текущее время размыкания без джиттера, растет при повторных
+	// размыканиях из полуоткрытого состояния и сбрасывается при замыкании
+	breakDelay time.Duration
+	// openDuration время размыкания с джиттером, выбранное при последнем размыкании
+	openDuration time.Duration
+	// halfOpenProbes количество пробных запросов в полете
+	halfOpenProbes uint
+
+	// pending смены состояния, callback-и которых вызываются после снятия блокировки
+	pending []stateChange
+	// notifyMu удерживается горутиной, которая вызывает callback-и смен состояния,
+	// что бы они вызывались по одному и в порядке смен
+	notifyMu sync.Mutex
+}
+
+// Counts счетчики вызовов с последней смены состояния цепи
+type Counts struct {
+	// Requests количество пропущенных вызовов
+	Requests uint
+	// Successes количество успешных вызовов
+	Successes uint
+	// Failures количество вызовов, завершившихся сбоем
+	Failures uint
+	// Ignored количество ошибок, которые классификатор не считает сбоями
+	Ignored uint
+	// Rejections количество отклоненных вызовов
+	Rejections uint
+	// ConsecutiveSuccesses количество успешных вызовов подряд
+	ConsecutiveSuccesses uint
+	// ConsecutiveFailures количество сбоев подряд
+	ConsecutiveFailures uint
+}
+
+// errPanicked результат вызова, fn которого запаниковала, всегда считается сбоем
+var errPanicked = errors.New("breaker: handler panicked")
+
+type stateChange struct {
+	from, to State
+}
+
+// New конструктор цепи circuit breaker-а
+func New(opts ...Option) *CircuitBreaker {
+	config := newDefaultOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	// без пробных слотов полуоткрытая цепь отклоняла бы все вызовы и не замыкалась
+	if config.halfOpenMaxProbes < 1 {
+		config.halfOpenMaxProbes = 1
+	}
+	cb := &CircuitBreaker{
+		config:     config,
+		state:      StateClosed,
+		window:     config.newWindow(),
+		breakDelay: config.breakDelay,
+	}
+	config.createCallback(cb.state)
+	return cb
+}
+
+// Execute выполняет fn через цепь. Если цепь разомкнута, fn не вызывается
+// и возвращается ошибка WithUnreachableError, иначе возвращается ошибка fn.
+// Паника fn учитывается как сбой и продолжается
+func (cb *CircuitBreaker) Execute(ctx context.Context, fn func(context.Context) error) (err error) {
+	generation, err := cb.allow()
+	if err != nil {
+		return err
+	}
+
+	start := cb.config.clock.Now()
+	panicked := true
+	defer func() {
+		if panicked {
+			err = errPanicked
+		}
+		cb.done(generation, err, cb.config.clock.Now().Sub(start))
+	}()
+
+	err = fn(ctx)
+	panicked = false
+	return err
+}
+
+// State возвращает текущее состояние цепи
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.checkBreakDelay(cb.config.clock.Now())
+	return cb.state
+}
+
+// currentState возвращает состояние цепи, не переводя разомкнутую цепь
+// в полуоткрытое состояние по истечении времени размыкания
+func (cb *CircuitBreaker) currentState() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// Counts возвращает счетчики вызовов с последней смены состояния цепи
+func (cb *CircuitBreaker) Counts() Counts {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.counts
+}
+
+// Reset замыкает цепь, сбрасывает счетчики и отменяет ForceOpen/ForceClosed
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = false
+	cb.setState(StateClosed, cb.config.clock.Now())
+}
+
+// ForceOpen размыкает цепь до вызова Reset или ForceClosed,
+// все вызовы отклоняются
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = true
+	cb.setState(StateOpen, cb.config.clock.Now())
+}
+
+// ForceClosed замыкает цепь до вызова Reset или ForceOpen,
+// все вызовы пропускаются независимо от сбоев
+func (cb *CircuitBreaker) ForceClosed() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = true
+	cb.setState(StateClosed, cb.config.clock.Now())
+}
+
+// allow решает пропустить ли вызов. Возвращает поколение, которое нужно передать
+// в done, или unreachableError если цепь разомкнута
+func (cb *CircuitBreaker) allow() (uint64, error) {
+	cb.mu.Lock()
+
+	cb.checkBreakDelay(cb.config.clock.Now())
+
+	reject := cb.state == StateOpen ||
+		cb.state == StateHalfOpen && cb.halfOpenProbes >= cb.config.halfOpenMaxProbes
+	if reject {
+		cb.counts.Rejections++
+		state := cb.state
+		cb.unlock()
+		cb.config.rejectCallback(state)
+		return 0, cb.config.unreachableError
+	}
+	if cb.state == StateHalfOpen {
+		cb.halfOpenProbes++
+	}
+	cb.counts.Requests++
+
+	generation := cb.generation
+	cb.unlock()
+	return generation, nil
+}
+
+// done учитывает результат вызова, пропущенного allow в поколении generation,
+// длившегося elapsed. Ошибки, которые классификатор не считает сбоями,
+// не влияют на состояние цепи
+func (cb *CircuitBreaker) done(generation uint64, err error, elapsed time.Duration) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if generation != cb.generation {
+		return
+	}
+	if cb.state == StateHalfOpen {
+		cb.halfOpenProbes--
+	}
+
+	if err != nil && err != errPanicked && !cb.config.failureClassifier(err) {
+		cb.counts.Ignored++
+		return
+	}
+
+	o := cb.config.outcome(err != nil, elapsed)
+	if o.failed {
+		cb.counts.Failures++
+		cb.counts.ConsecutiveFailures++
+		cb.counts.ConsecutiveSuccesses = 0
+	} else {
+		cb.counts.Successes++
+		cb.counts.ConsecutiveSuccesses++
+		cb.counts.ConsecutiveFailures = 0
+	}
+	if cb.forced {
+		return
+	}
+
+	now := cb.config.clock.Now()
+	switch cb.state {
+	case StateClosed:
+		cb.window.record(now, o)
+		if cb.shouldTrip(now) {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if o.failed || o.slow {
+			cb.setState(StateOpen, now)
+			return
+		}
+		if cb.counts.ConsecutiveSuccesses >= cb.config.halfOpenSuccessThreshold {
+			cb.setState(StateClosed, now)
+		}
+	}
+}
+
+// checkBreakDelay переводит разомкнутую цепь в полуоткрытое состояние,
+// если время размыкания истекло, вызывается под блокировкой
+func (cb *CircuitBreaker) checkBreakDelay(now time.Time) {
+	if cb.forced || cb.state != StateOpen {
+		return
+	}
+	if !now.Before(cb.openedAt.Add(cb.openDuration)) {
+		cb.setState(StateHalfOpen, now)
+	}
+}
+
+// shouldTrip проверяет пора ли разомкнуть замкнутую цепь, вызывается под блокировкой
+func (cb *CircuitBreaker) shouldTrip(now time.Time) bool {
+	counts := cb.window.counts(now)
+	enoughRequests := counts.total >= cb.config.minimumRequests
+
+	if cb.config.slowCallRateThreshold > 0 && enoughRequests &&
+		counts.slowRate() >= cb.config.slowCallRateThreshold {
+		return true
+	}
+	if cb.config.failureRateThreshold <= 0 {
+		return cb.counts.ConsecutiveFailures > cb.config.failureThreshold
+	}
+	return enoughRequests && counts.failureRate() >= cb.config.failureRateThreshold
+}
+
+// setState меняет состояние и сбрасывает счетчики, вызывается под блокировкой
+func (cb *CircuitBreaker) setState(to State, now time.Time) {
+	from := cb.state
+	if from != to {
+		cb.pending = append(cb.pending, stateChange{from: from, to: to})
+	}
+	cb.state = to
+	cb.generation++
+	cb.counts = Counts{}
+	cb.halfOpenProbes = 0
+	switch to {
+	case StateOpen:
+		if from == StateHalfOpen {
+			cb.breakDelay = cb.config.nextBreakDelay(cb.breakDelay)
+		}
+		cb.openedAt = now
+		cb.openDuration = cb.config.breakDelayJitter(cb.breakDelay)
+	case StateClosed:
+		cb.window.reset()
+		cb.breakDelay = cb.config.breakDelay
+	}
+}
+
+// unlock снимает блокировку и вызывает callback-и накопленных смен состояния.
+// Если callback-и уже вызывает другая горутина (или callback, обратившийся к цепи),
+// она вызовет и накопленные сейчас, сохраняя порядок смен
+func (cb *CircuitBreaker) unlock() {
+	cb.mu.Unlock()
+
+	for cb.hasPending() && cb.notifyMu.TryLock() {
+		for pending := cb.takePending(); len(pending) > 0; pending = cb.takePending() {
+			for _, change := range pending {
+				cb.config.stateChangeCallback(change.from, change.to)
+			}
+		}
+		// смены, накопленные после последней проверки, проверяются снова
+		// после освобождения notifyMu
+		cb.notifyMu.Unlock()
+	}
+}
+
+// hasPending есть ли смены состояния, callback-и которых еще не вызваны
+func (cb *CircuitBreaker) hasPending() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return len(cb.pending) > 0
+}
+
+// takePending забирает накопленные смены состояния
+func (cb *CircuitBreaker) takePending() []stateChange {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	pending := cb.pending
+	cb.pending = nil
+	return pending
+}
@@ -0,0 +1,171 @@
+package breaker
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+func newTestCircuitBreaker(opts ...Option) (*CircuitBreaker, *clocktest.Fake) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	cb := New(append([]Option{WithClock(fake), WithUnreachableError(errUnreachable)}, opts...)...)
+	return cb, fake
+}
+
+func execute(cb *CircuitBreaker, err error) error {
+	return cb.Execute(context.Background(), func(context.Context) error { return err })
+}
+
+func TestCircuitBreakerStateAndCounts(t *testing.T) {
+	cb, fake := newTestCircuitBreaker(WithFailureThreshold(1), WithBreakDelay(time.Second))
+
+	require.Equal(t, StateClosed, cb.State())
+	require.NoError(t, execute(cb, nil))
+	require.ErrorIs(t, execute(cb, errHandler), errHandler)
+	require.Equal(t, Counts{
+		Requests:            2,
+		Successes:           1,
+		Failures:            1,
+		ConsecutiveFailures: 1,
+	}, cb.Counts())
+
+	require.ErrorIs(t, execute(cb, errHandler), errHandler)
+	require.Equal(t, StateOpen, cb.State())
+	require.Equal(t, Counts{}, cb.Counts())
+
+	require.ErrorIs(t, execute(cb, nil), errUnreachable)
+	require.Equal(t, Counts{Rejections: 1}, cb.Counts())
+
+	fake.Advance(time.Second)
+	require.Equal(t, StateHalfOpen, cb.State())
+	require.NoError(t, execute(cb, nil))
+	require.Equal(t, StateClosed, cb.State())
+}
+
+func TestCircuitBreakerManualControl(t *testing.T) {
+	var changes []string
+	cb, fake := newTestCircuitBreaker(
+		WithFailureThreshold(0),
+		WithBreakDelay(time.Second),
+		WithStateChangeCallback(func(from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	)
+
+	cb.ForceOpen()
+	require.Equal(t, StateOpen, cb.State())
+	fake.Advance(time.Hour)
+	require.Equal(t, StateOpen, cb.State(), "forced open circuit never becomes half-open")
+	require.ErrorIs(t, execute(cb, nil), errUnreachable)
+
+	cb.ForceClosed()
+	require.ErrorIs(t, execute(cb, errHandler), errHandler)
+	require.ErrorIs(t, execute(cb, errHandler), errHandler)
+	require.Equal(t, StateClosed, cb.State(), "forced closed circuit never trips")
+	require.Equal(t, uint(2), cb.Counts().ConsecutiveFailures)
+
+	cb.Reset()
+	require.Equal(t, Counts{}, cb.Counts())
+	require.ErrorIs(t, execute(cb, errHandler), errHandler)
+	require.Equal(t, StateOpen, cb.State())
+
+	cb.Reset()
+	require.Equal(t, StateClosed, cb.State())
+	require.Equal(t, []string{"closed->open", "open->closed", "closed->open", "open->closed"}, changes)
+}
+
+func TestCircuitBreakerResetIgnoresStaleCalls(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(WithFailureThreshold(0))
+
+	err := cb.Execute(context.Background(), func(context.Context) error {
+		cb.Reset()
+		return errHandler
+	})
+	require.ErrorIs(t, err, errHandler)
+	require.Equal(t, StateClosed, cb.State())
+	require.Equal(t, Counts{}, cb.Counts())
+}
+
+func TestWrap(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(WithFailureThreshold(0))
+	handler := Wrap(cb, func(_ context.Context, req int) (int, error) {
+		if req < 0 {
+			return 0, errHandler
+		}
+		return req * 2, nil
+	})
+
+	resp, err := handler(context.Background(), 21)
+	require.NoError(t, err)
+	require.Equal(t, 42, resp)
+
+	_, err = handler(context.Background(), -1)
+	require.ErrorIs(t, err, errHandler)
+	require.Equal(t, StateOpen, cb.State())
+
+	_, err = handler(context.Background(), 1)
+	require.ErrorIs(t, err, errUnreachable)
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	cb, fake := newTestCircuitBreaker(WithFailureThreshold(0), WithBreakDelay(time.Second))
+	executePanic := func() {
+		defer func() {
+			require.Equal(t, "boom", recover())
+		}()
+		_ = cb.Execute(context.Background(), func(context.Context) error { panic("boom") })
+	}
+
+	// паника считается сбоем и размыкает цепь
+	executePanic()
+	require.Equal(t, StateOpen, cb.State())
+
+	// паника пробного запроса освобождает его слот и снова размыкает цепь
+	fake.Advance(time.Second)
+	executePanic()
+	require.Equal(t, StateOpen, cb.State())
+	fake.Advance(time.Second)
+	require.NoError(t, execute(cb, nil))
+	require.Equal(t, StateClosed, cb.State())
+}
+
+func TestCircuitBreakerStateChangeOrder(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		changes []stateChange
+	)
+	cb, _ := newTestCircuitBreaker(WithStateChangeCallback(func(from, to State) {
+		// уступаем процессор, что бы callback-и разных горутин перемешались
+		runtime.Gosched()
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, stateChange{from: from, to: to})
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				cb.ForceOpen()
+				cb.ForceClosed()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// каждая смена начинается с состояния, в которое перешла предыдущая
+	state := StateClosed
+	for i, change := range changes {
+		require.Equal(t, state, change.from, "change %d", i)
+		state = change.to
+	}
+	require.Equal(t, cb.State(), state)
+}
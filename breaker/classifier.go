@@ -0,0 +1,42 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClientGRPCCodes коды ответа gRPC, которые означают ошибку на стороне клиента
+// и не говорят о проблемах сервиса
+var ClientGRPCCodes = []codes.Code{
+	codes.Canceled,
+	codes.InvalidArgument,
+	codes.NotFound,
+	codes.AlreadyExists,
+	codes.PermissionDenied,
+	codes.FailedPrecondition,
+	codes.OutOfRange,
+	codes.Unauthenticated,
+}
+
+// DefaultFailureClassifier классификатор ошибок по умолчанию: отмена контекста
+// вызывающей стороной и ошибки с кодами ClientGRPCCodes не считаются сбоями,
+// любая другая ошибка считается
+func DefaultFailureClassifier(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return true
+	}
+	code := grpcErr.GRPCStatus().Code()
+	for _, clientCode := range ClientGRPCCodes {
+		if code == clientCode {
+			return false
+		}
+	}
+	return true
+}
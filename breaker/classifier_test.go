@@ -0,0 +1,115 @@
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDefaultFailureClassifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputErr    error
+		wantFailure bool
+	}{
+		{
+			name:        "plain",
+			inputErr:    errHandler,
+			wantFailure: true,
+		},
+		{
+			name:        "context_canceled",
+			inputErr:    fmt.Errorf("call: %w", context.Canceled),
+			wantFailure: false,
+		},
+		{
+			name:        "context_deadline",
+			inputErr:    context.DeadlineExceeded,
+			wantFailure: true,
+		},
+		{
+			name:        "grpc_invalid_argument",
+			inputErr:    status.Error(codes.InvalidArgument, "invalid"),
+			wantFailure: false,
+		},
+		{
+			name:        "wrapped_grpc_not_found",
+			inputErr:    fmt.Errorf("call: %w", status.Error(codes.NotFound, "not found")),
+			wantFailure: false,
+		},
+		{
+			name:        "grpc_unavailable",
+			inputErr:    status.Error(codes.Unavailable, "unavailable"),
+			wantFailure: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.wantFailure, DefaultFailureClassifier(test.inputErr))
+		})
+	}
+}
+
+func TestBreakerFailureClassifier(t *testing.T) {
+	errInvalid := status.Error(codes.InvalidArgument, "invalid")
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		steps []step
+	}{
+		{
+			name: "client_errors_ignored",
+			opts: []Option{WithFailureThreshold(1)},
+			steps: []step{
+				{handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+			},
+		},
+		{
+			name: "ignored_does_not_reset_failures",
+			opts: []Option{WithFailureThreshold(1)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+		{
+			name: "ignored_probe_releases_slot",
+			opts: []Option{WithFailureThreshold(0), WithBreakDelay(time.Second)},
+			steps: []step{
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+				{advance: time.Second, handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{wantErr: nil, wantCalled: true},
+				{handlerErr: errHandler, wantErr: errHandler, wantCalled: true},
+			},
+		},
+		{
+			name: "custom_classifier",
+			opts: []Option{
+				WithFailureThreshold(0),
+				WithFailureClassifier(func(error) bool { return true }),
+			},
+			steps: []step{
+				{handlerErr: errInvalid, wantErr: errInvalid, wantCalled: true},
+				{wantErr: errUnreachable, wantCalled: false},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runSteps(t, test.opts, test.steps)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// Group набор независимых цепей breaker-а по ключу, вычисляемому из запроса
+// (например хост шарда или метод). Цепи создаются лениво при первом запросе
+// с ключом. Замкнутые цепи, к которым не обращались дольше WithIdleTimeout,
+// удаляются
+type Group[K comparable, RespT, ReqT any] struct {
+	key        func(ReqT) K
+	handler    func(context.Context, ReqT) (RespT, error)
+	keyOptions func(key K) []Option
+	config     *groupOptions
+
+	mu        sync.Mutex
+	circuits  map[K]*groupCircuit[RespT, ReqT]
+	lastEvict time.Time
+}
+
+// groupCircuit цепь ключа и обработчик, обернутый ею
+type groupCircuit[RespT, ReqT any] struct {
+	cb       *CircuitBreaker
+	handler  func(context.Context, ReqT) (RespT, error)
+	lastUsed time.Time
+}
+
+// NewGroup конструктор набора цепей, key вычисляет ключ цепи по запросу.
+// Паникует, если тип ключа в WithGroupKeyOptions отличается от K
+func NewGroup[K comparable, RespT, ReqT any](
+	key func(ReqT) K,
+	handler func(context.Context, ReqT) (RespT, error),
+	opts ...GroupOption,
+) *Group[K, RespT, ReqT] {
+	config := newDefaultGroupOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	keyOptions := func(K) []Option { return nil }
+	if config.keyOptions != nil {
+		var ok bool
+		if keyOptions, ok = config.keyOptions.(func(K) []Option); !ok {
+			panic(fmt.Sprintf("breaker: WithGroupKeyOptions key type of %T does not match group key", config.keyOptions))
+		}
+	}
+	return &Group[K, RespT, ReqT]{
+		key:        key,
+		handler:    handler,
+		keyOptions: keyOptions,
+		config:     config,
+		circuits:   make(map[K]*groupCircuit[RespT, ReqT]),
+		lastEvict:  config.clock.Now(),
+	}
+}
+
+// Handle выполняет запрос через цепь его ключа
+func (g *Group[K, RespT, ReqT]) Handle(ctx context.Context, req ReqT) (RespT, error) {
+	key := g.key(req)
+	circuit := g.get(key)
+	resp, err := circuit.handler(ctx, req)
+
+	g.mu.Lock()
+	circuit.lastUsed = g.config.clock.Now()
+	g.mu.Unlock()
+	return resp, err
+}
+
+// Get возвращает цепь ключа (например для ForceOpen во время инцидента),
+// создавая ее если ее еще нет
+func (g *Group[K, RespT, ReqT]) Get(key K) *CircuitBreaker {
+	return g.get(key).cb
+}
+
+// States возвращает состояния цепей всех ключей набора
+func (g *Group[K, RespT, ReqT]) States() map[K]State {
+	states := make(map[K]State)
+	for key, circuit := range g.snapshot() {
+		states[key] = circuit.cb.State()
+	}
+	return states
+}
+
+// get возвращает цепь ключа, создавая ее при первом обращении,
+// и удаляет простаивающие цепи
+func (g *Group[K, RespT, ReqT]) get(key K) *groupCircuit[RespT, ReqT] {
+	now := g.config.clock.Now()
+	g.evictIdle(now)
+
+	g.mu.Lock()
+	circuit, ok := g.circuits[key]
+	if ok {
+		circuit.lastUsed = now
+		g.mu.Unlock()
+		return circuit
+	}
+	g.mu.Unlock()
+
+	// настройки ключа вычисляются без блокировки, так как вызывают код пользователя,
+	// общие настройки применяются раньше настроек ключа
+	opts := append([]Option(nil), g.config.breakerOptions...)
+	cb := New(append(opts, g.keyOptions(key)...)...)
+	created := &groupCircuit[RespT, ReqT]{
+		cb:      cb,
+		handler: Wrap(cb, g.handler),
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// цепь ключа могла быть создана параллельно
+	if circuit, ok = g.circuits[key]; !ok {
+		circuit = created
+		g.circuits[key] = circuit
+	}
+	circuit.lastUsed = now
+	return circuit
+}
+
+// snapshot возвращает копию набора цепей
+func (g *Group[K, RespT, ReqT]) snapshot() map[K]*groupCircuit[RespT, ReqT] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	circuits := make(map[K]*groupCircuit[RespT, ReqT], len(g.circuits))
+	for key, circuit := range g.circuits {
+		circuits[key] = circuit
+	}
+	return circuits
+}
+
+// evictIdle удаляет замкнутые цепи, к которым не обращались дольше idleTimeout.
+// Проверка выполняется не чаще раза в idleTimeout. Состояние цепей читается
+// без перевода разомкнутых цепей в полуоткрытое состояние, что бы проверка
+// не меняла цепи ключей, к которым никто не обращается
+func (g *Group[K, RespT, ReqT]) evictIdle(now time.Time) {
+	idleTimeout := g.config.idleTimeout
+	if idleTimeout <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.lastEvict) < idleTimeout {
+		return
+	}
+	g.lastEvict = now
+	for key, circuit := range g.circuits {
+		if now.Sub(circuit.lastUsed) >= idleTimeout && circuit.cb.currentState() == StateClosed {
+			delete(g.circuits, key)
+		}
+	}
+}
+
+// GroupOption функция для изменения настроек Group
+type GroupOption func(o *groupOptions)
+
+type groupOptions struct {
+	breakerOptions []Option
+	// keyOptions func(key K) []Option, тип проверяется в NewGroup
+	keyOptions  any
+	idleTimeout time.Duration
+	clock       clock.Clock
+}
+
+// Настройки Group по умолчанию
+var (
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+// newDefaultGroupOptions конструктор настроек по умолчанию
+func newDefaultGroupOptions() *groupOptions {
+	return &groupOptions{
+		idleTimeout: defaultIdleTimeout,
+		clock:       clock.New(),
+	}
+}
+
+// WithGroupBreakerOptions настройка цепей, одинаковая для всех ключей.
+// Callback-и из opts общие для всех цепей, для callback-ов, которым нужен
+// ключ, используйте WithGroupKeyOptions
+func WithGroupBreakerOptions(opts ...Option) GroupOption {
+	return func(o *groupOptions) {
+		o.breakerOptions = append(o.breakerOptions, opts...)
+	}
+}
+
+// WithGroupKeyOptions настройка функции, которая возвращает настройки цепи
+// по ключу, например metrics.BreakerOptions с ключом в имени. Применяются
+// после WithGroupBreakerOptions при создании цепи ключа. K должен совпадать
+// с типом ключа Group
+func WithGroupKeyOptions[K comparable](keyOptions func(key K) []Option) GroupOption {
+	return func(o *groupOptions) {
+		o.keyOptions = keyOptions
+	}
+}
+
+// WithIdleTimeout настройка времени простоя, после которого Group удаляет
+// замкнутую цепь ключа (0 - не удалять)
+func WithIdleTimeout(timeout time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.idleTimeout = timeout
+	}
+}
+
+// WithGroupClock настройка источника времени для учета простоя цепей
+// (используется в тестах, часы цепей задаются через WithClock)
+func WithGroupClock(clk clock.Clock) GroupOption {
+	return func(o *groupOptions) {
+		o.clock = clk
+	}
+}
@@ -0,0 +1,114 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+type shardRequest struct {
+	shard string
+	err   error
+}
+
+func TestGroup(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	group := NewGroup(
+		func(req shardRequest) string { return req.shard },
+		func(_ context.Context, req shardRequest) (string, error) { return req.shard, req.err },
+		WithGroupBreakerOptions(
+			WithClock(fake),
+			WithUnreachableError(errUnreachable),
+			WithFailureThreshold(0),
+			WithBreakDelay(time.Hour),
+		),
+		WithGroupClock(fake),
+		WithIdleTimeout(time.Minute),
+	)
+	ctx := context.Background()
+
+	_, err := group.Handle(ctx, shardRequest{shard: "a", err: errHandler})
+	require.ErrorIs(t, err, errHandler)
+	_, err = group.Handle(ctx, shardRequest{shard: "a"})
+	require.ErrorIs(t, err, errUnreachable)
+
+	// цепь другого ключа не зависит от сбоев первого
+	resp, err := group.Handle(ctx, shardRequest{shard: "b"})
+	require.NoError(t, err)
+	require.Equal(t, "b", resp)
+	require.Equal(t, map[string]State{"a": StateOpen, "b": StateClosed}, group.States())
+
+	group.Get("c").ForceOpen()
+	_, err = group.Handle(ctx, shardRequest{shard: "c"})
+	require.ErrorIs(t, err, errUnreachable)
+
+	// простаивающие замкнутые цепи удаляются, разомкнутые остаются
+	fake.Advance(time.Minute)
+	group.Get("a")
+	require.Equal(t, map[string]State{"a": StateOpen, "c": StateOpen}, group.States())
+}
+
+func TestGroupCallbacks(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	var (
+		group   *Group[string, string, shardRequest]
+		changes []string
+	)
+	group = NewGroup(
+		func(req shardRequest) string { return req.shard },
+		func(_ context.Context, req shardRequest) (string, error) { return req.shard, req.err },
+		WithGroupBreakerOptions(WithClock(fake), WithFailureThreshold(0), WithBreakDelay(time.Second)),
+		WithGroupKeyOptions(func(key string) []Option {
+			return []Option{WithStateChangeCallback(func(from, to State) {
+				changes = append(changes, key+": "+from.String()+"->"+to.String())
+				// callback может обращаться к набору
+				group.States()
+			})}
+		}),
+		WithGroupClock(fake),
+		WithIdleTimeout(time.Second),
+	)
+	ctx := context.Background()
+
+	_, err := group.Handle(ctx, shardRequest{shard: "a", err: errHandler})
+	require.ErrorIs(t, err, errHandler)
+
+	// проверка простоя не меняет цепи ключей, к которым не обращаются
+	fake.Advance(time.Second)
+	_, err = group.Handle(ctx, shardRequest{shard: "b"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a: closed->open"}, changes)
+	require.Equal(t, StateOpen, group.Get("a").currentState())
+
+	_, err = group.Handle(ctx, shardRequest{shard: "a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a: closed->open", "a: open->half-open", "a: half-open->closed"}, changes)
+}
+
+func TestGroupKeyOptions(t *testing.T) {
+	var keys []int
+	group := NewGroup(
+		func(req int) int { return req },
+		func(_ context.Context, req int) (int, error) { return req, nil },
+		WithGroupKeyOptions(func(key int) []Option {
+			keys = append(keys, key)
+			return nil
+		}),
+	)
+	_, err := group.Handle(context.Background(), 7)
+	require.NoError(t, err)
+	require.Equal(t, []int{7}, keys)
+
+	// тип ключа настроек должен совпадать с типом ключа набора
+	require.Panics(t, func() {
+		NewGroup(
+			func(req int) int { return req },
+			func(_ context.Context, req int) (int, error) { return req, nil },
+			WithGroupKeyOptions(func(key string) []Option { return nil }),
+		)
+	})
+}
@@ -0,0 +1,33 @@
+package breaker
+
+// State состояние цепи
+type State int
+
+const (
+	// StateClosed цепь замкнута, запросы проходят
+	StateClosed State = iota
+	// StateOpen цепь разомкнута, запросы отклоняются
+	StateOpen
+	// StateHalfOpen цепь полуоткрыта, пропускается ограниченное число пробных запросов
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChangeCallback функция которая вызывается при смене состояния цепи
+type StateChangeCallback func(from, to State)
+
+// RejectCallback функция которая вызывается каждый раз когда цепь отклоняет вызов,
+// получает состояние цепи (разомкнута или полуоткрыта без свободных пробных слотов)
+type RejectCallback func(state State)
@@ -0,0 +1,142 @@
+package breaker
+
+import "time"
+
+// outcome результат вызова, учитываемый скользящим окном
+type outcome struct {
+	failed bool
+	slow   bool
+}
+
+// windowCounts счетчики вызовов в скользящем окне
+type windowCounts struct {
+	total    uint
+	failures uint
+	slow     uint
+}
+
+// add учитывает результат вызова
+func (c *windowCounts) add(o outcome) {
+	c.total++
+	if o.failed {
+		c.failures++
+	}
+	if o.slow {
+		c.slow++
+	}
+}
+
+// sub убирает результат вызова
+func (c *windowCounts) sub(o outcome) {
+	c.total--
+	if o.failed {
+		c.failures--
+	}
+	if o.slow {
+		c.slow--
+	}
+}
+
+// failureRate доля неудачных вызовов
+func (c windowCounts) failureRate() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.failures) / float64(c.total)
+}
+
+// slowRate доля медленных вызовов
+func (c windowCounts) slowRate() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.slow) / float64(c.total)
+}
+
+// window скользящее окно последних вызовов
+type window interface {
+	record(now time.Time, o outcome)
+	counts(now time.Time) windowCounts
+	reset()
+}
+
+// countWindow окно из size последних вызовов
+type countWindow struct {
+	outcomes []outcome
+	next     int
+	filled   bool
+	total    windowCounts
+}
+
+func newCountWindow(size uint) *countWindow {
+	if size == 0 {
+		size = 1
+	}
+	return &countWindow{outcomes: make([]outcome, size)}
+}
+
+func (w *countWindow) record(_ time.Time, o outcome) {
+	if w.filled {
+		w.total.sub(w.outcomes[w.next])
+	}
+	w.outcomes[w.next] = o
+	w.total.add(o)
+	w.next++
+	if w.next == len(w.outcomes) {
+		w.next = 0
+		w.filled = true
+	}
+}
+
+func (w *countWindow) counts(_ time.Time) windowCounts {
+	return w.total
+}
+
+func (w *countWindow) reset() {
+	*w = countWindow{outcomes: make([]outcome, len(w.outcomes))}
+}
+
+// timeWindow окно вызовов за последний интервал, разбитый на посекундные корзины
+type timeWindow struct {
+	buckets []timeBucket
+}
+
+type timeBucket struct {
+	second int64
+	counts windowCounts
+}
+
+func newTimeWindow(d time.Duration) *timeWindow {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &timeWindow{buckets: make([]timeBucket, seconds)}
+}
+
+func (w *timeWindow) record(now time.Time, o outcome) {
+	second := now.Unix()
+	bucket := &w.buckets[second%int64(len(w.buckets))]
+	if bucket.second != second {
+		*bucket = timeBucket{second: second}
+	}
+	bucket.counts.add(o)
+}
+
+func (w *timeWindow) counts(now time.Time) windowCounts {
+	second := now.Unix()
+	var total windowCounts
+	for _, bucket := range w.buckets {
+		if second-bucket.second >= int64(len(w.buckets)) {
+			continue
+		}
+		total.total += bucket.counts.total
+		total.failures += bucket.counts.failures
+		total.slow += bucket.counts.slow
+	}
+	return total
+}
+
+func (w *timeWindow) reset() {
+	*w = timeWindow{buckets: make([]timeBucket, len(w.buckets))}
+}
@@ -0,0 +1,43 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountWindow(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newCountWindow(3)
+
+	w.record(now, outcome{failed: true})
+	w.record(now, outcome{failed: false})
+	require.Equal(t, windowCounts{total: 2, failures: 1}, w.counts(now))
+
+	w.record(now, outcome{failed: true})
+	require.Equal(t, windowCounts{total: 3, failures: 2}, w.counts(now))
+
+	// первый вызов вытесняется из окна
+	w.record(now, outcome{failed: false})
+	require.Equal(t, windowCounts{total: 3, failures: 1}, w.counts(now))
+
+	w.reset()
+	require.Equal(t, windowCounts{}, w.counts(now))
+}
+
+func TestTimeWindow(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTimeWindow(2 * time.Second)
+
+	w.record(now, outcome{failed: true})
+	w.record(now.Add(time.Second), outcome{failed: false})
+	require.Equal(t, windowCounts{total: 2, failures: 1}, w.counts(now.Add(time.Second)))
+
+	// первая секунда вышла из окна
+	require.Equal(t, windowCounts{total: 1, failures: 0}, w.counts(now.Add(2*time.Second)))
+	require.Equal(t, windowCounts{}, w.counts(now.Add(3*time.Second)))
+
+	w.record(now.Add(3*time.Second), outcome{failed: true})
+	require.Equal(t, windowCounts{total: 1, failures: 1}, w.counts(now.Add(3*time.Second)))
+}
@@ -0,0 +1,134 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBulkheadFull ошибка, которую возвращает обработчик Bulkhead-а,
+// если все слоты и места в очереди заняты
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// ErrQueueTimeout ошибка, которую возвращает обработчик Bulkhead-а, если контекст
+// вызова завершился пока вызов ждал слот в очереди, оборачивает ctx.Err()
+var ErrQueueTimeout = errors.New("bulkhead queue wait interrupted")
+
+// Bulkhead is an implementation of a Bulkhead design pattern
+//
+// It limits how many calls of handler run concurrently, so a slow dependency
+// cannot use up all goroutines of the service. At most maxConcurrent calls
+// run at once and at most maxQueue more callers wait for a free slot.
+// A waiting caller gives up with ErrQueueTimeout (wrapping ctx.Err()) as soon
+// as its context is done, callers that find the queue full are rejected with
+// ErrBulkheadFull.
+func Bulkhead[RespT, ReqT any](
+	handler func(context.Context, ReqT) (RespT, error),
+	opts ...Option,
+) func(context.Context, ReqT) (RespT, error) {
+	config := newDefaultOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	// без слотов не выполнился бы ни один вызов
+	if config.maxConcurrent < 1 {
+		config.maxConcurrent = 1
+	}
+	b := &bulkhead{
+		slots:    make(chan struct{}, config.maxConcurrent),
+		maxQueue: config.maxQueue,
+	}
+
+	return func(ctx context.Context, req ReqT) (RespT, error) {
+		if err := b.acquire(ctx); err != nil {
+			var zero RespT
+			return zero, err
+		}
+		defer b.release()
+
+		return handler(ctx, req)
+	}
+}
+
+// bulkhead слоты одновременных вызовов и очередь ожидающих слот
+type bulkhead struct {
+	slots    chan struct{}
+	maxQueue uint
+
+	mu     sync.Mutex
+	queued uint
+}
+
+// acquire занимает слот, при необходимости ожидая его в очереди
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	b.mu.Lock()
+	if b.queued >= b.maxQueue {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	b.queued++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.queued--
+		b.mu.Unlock()
+	}()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrQueueTimeout, ctx.Err())
+	}
+}
+
+// release освобождает слот
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+// Option функция для изменения настроек поведения Bulkhead-а
+type Option func(o *options)
+
+type options struct {
+	maxConcurrent uint
+	maxQueue      uint
+}
+
+// Настройки Bulkhead-а по умолчанию
+var (
+	defaultMaxConcurrent uint = 10
+	defaultMaxQueue      uint = 0
+)
+
+// newDefaultOptions конструктор настроек по умолчанию
+func newDefaultOptions() *options {
+	return &options{
+		maxConcurrent: defaultMaxConcurrent,
+		maxQueue:      defaultMaxQueue,
+	}
+}
+
+// WithMaxConcurrent настройка максимального количества одновременных вызовов
+// (0 считается как 1)
+func WithMaxConcurrent(maxConcurrent uint) Option {
+	return func(o *options) {
+		o.maxConcurrent = maxConcurrent
+	}
+}
+
+// WithMaxQueue настройка максимального количества вызовов, ожидающих
+// свободный слот (0 - отклонять сразу)
+func WithMaxQueue(maxQueue uint) Option {
+	return func(o *options) {
+		o.maxQueue = maxQueue
+	}
+}
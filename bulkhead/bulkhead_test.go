@@ -0,0 +1,108 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkhead(t *testing.T) {
+	var (
+		started = make(chan int, 10)
+		release = make(chan struct{})
+	)
+	handler := Bulkhead(
+		func(_ context.Context, req int) (int, error) {
+			started <- req
+			<-release
+			return req, nil
+		},
+		WithMaxConcurrent(1),
+		WithMaxQueue(1),
+	)
+	type result struct {
+		resp int
+		err  error
+	}
+	call := func(ctx context.Context, req int) <-chan result {
+		done := make(chan result, 1)
+		go func() {
+			resp, err := handler(ctx, req)
+			done <- result{resp: resp, err: err}
+		}()
+		return done
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	first := call(context.Background(), 1)
+	require.Equal(t, 1, <-started)
+
+	queuedCtx, cancelQueued := context.WithCancel(context.Background())
+	queued := call(queuedCtx, 2)
+	// пока второй вызов не в очереди, вызов с отмененным контекстом
+	// сам встает в очередь и сразу завершается ошибкой ErrQueueTimeout
+	require.Eventually(t, func() bool {
+		_, err := handler(canceled, 3)
+		return errors.Is(err, ErrBulkheadFull)
+	}, time.Second, time.Millisecond)
+
+	cancelQueued()
+	queuedResult := <-queued
+	require.ErrorIs(t, queuedResult.err, ErrQueueTimeout)
+	require.ErrorIs(t, queuedResult.err, context.Canceled)
+
+	next := call(context.Background(), 4)
+	require.Eventually(t, func() bool {
+		_, err := handler(canceled, 5)
+		return errors.Is(err, ErrBulkheadFull)
+	}, time.Second, time.Millisecond)
+
+	release <- struct{}{}
+	require.Equal(t, result{resp: 1}, <-first)
+	require.Equal(t, 4, <-started)
+	release <- struct{}{}
+	require.Equal(t, result{resp: 4}, <-next)
+}
+
+func TestBulkheadRejectWithoutQueue(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := Bulkhead(
+		func(_ context.Context, _ struct{}) (struct{}, error) {
+			close(started)
+			<-release
+			return struct{}{}, nil
+		},
+		WithMaxConcurrent(1),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := handler(context.Background(), struct{}{})
+		done <- err
+	}()
+	<-started
+
+	_, err := handler(context.Background(), struct{}{})
+	require.ErrorIs(t, err, ErrBulkheadFull)
+
+	close(release)
+	require.NoError(t, <-done)
+}
+
+func TestBulkheadZeroMaxConcurrent(t *testing.T) {
+	handler := Bulkhead(
+		func(_ context.Context, req int) (int, error) { return req, nil },
+		WithMaxConcurrent(0),
+		WithMaxQueue(0),
+	)
+
+	// 0 слотов считается как 1
+	resp, err := handler(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, resp)
+}
@@ -0,0 +1,40 @@
+package clock
+
+import "time"
+
+// Clock источник времени, позволяет подменять реальное время в тестах
+type Clock interface {
+	// Now возвращает текущее время
+	Now() time.Time
+	// NewTimer создает таймер, который сработает через d
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer таймер созданный Clock
+type Timer interface {
+	// C канал в который придет время срабатывания таймера
+	C() <-chan time.Time
+	// Stop останавливает таймер, возвращает false если таймер уже сработал или остановлен
+	Stop() bool
+}
+
+// New конструктор часов, работающих по реальному времени
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.timer.C }
+
+func (t realTimer) Stop() bool { return t.timer.Stop() }
@@ -0,0 +1,142 @@
+package clocktest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// Fake часы, время которых двигается только вручную через Advance.
+// Используются в тестах, что бы не ждать реальное время
+type Fake struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake конструктор ручных часов, которые показывают время now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now возвращает текущее время часов
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer создает таймер, который сработает когда часы продвинутся на d
+func (f *Fake) NewTimer(d time.Duration) clock.Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	timer := &fakeTimer{
+		fake:     f,
+		c:        make(chan time.Time, 1),
+		deadline: f.now.Add(d),
+	}
+	if d <= 0 {
+		timer.c <- f.now
+		return timer
+	}
+	f.timers = append(f.timers, timer)
+	f.cond.Broadcast()
+	return timer
+}
+
+// Advance продвигает часы на d и срабатывает все таймеры, время которых наступило
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	sort.Slice(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	active := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.deadline.After(f.now) {
+			active = append(active, timer)
+			continue
+		}
+		timer.c <- timer.deadline
+	}
+	f.timers = active
+	f.cond.Broadcast()
+}
+
+// BlockUntil блокируется пока количество активных таймеров не станет равно n.
+// Нужен что бы дождаться пока тестируемый код начнет ждать таймер
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.timers) != n {
+		f.cond.Wait()
+	}
+}
+
+// BlockUntilContext как BlockUntil, но перестает ждать когда ctx завершен
+// и возвращает ctx.Err(). Нужен если тестируемый код может завершиться,
+// так и не начав ждать таймер
+func (f *Fake) BlockUntilContext(ctx context.Context, n int) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			f.cond.Broadcast()
+			f.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.timers) != n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f.cond.Wait()
+	}
+	return nil
+}
+
+// Timers возвращает количество активных таймеров
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+type fakeTimer struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+
+	for i, timer := range t.fake.timers {
+		if timer == t {
+			t.fake.timers = append(t.fake.timers[:i], t.fake.timers[i+1:]...)
+			t.fake.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package clocktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		inputTimer   time.Duration
+		inputAdvance []time.Duration
+		wantFired    bool
+		wantNow      time.Time
+	}{
+		{
+			name:         "not_fired",
+			inputTimer:   time.Second,
+			inputAdvance: []time.Duration{500 * time.Millisecond},
+			wantFired:    false,
+			wantNow:      start.Add(500 * time.Millisecond),
+		},
+		{
+			name:         "fired_exactly",
+			inputTimer:   time.Second,
+			inputAdvance: []time.Duration{time.Second},
+			wantFired:    true,
+			wantNow:      start.Add(time.Second),
+		},
+		{
+			name:         "fired_after_several_advances",
+			inputTimer:   time.Second,
+			inputAdvance: []time.Duration{600 * time.Millisecond, 600 * time.Millisecond},
+			wantFired:    true,
+			wantNow:      start.Add(1200 * time.Millisecond),
+		},
+		{
+			name:         "zero_duration",
+			inputTimer:   0,
+			inputAdvance: nil,
+			wantFired:    true,
+			wantNow:      start,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFake(start)
+			timer := fake.NewTimer(test.inputTimer)
+			for _, d := range test.inputAdvance {
+				fake.Advance(d)
+			}
+
+			require.Equal(t, test.wantNow, fake.Now())
+			select {
+			case <-timer.C():
+				require.True(t, test.wantFired)
+				require.Equal(t, 0, fake.Timers())
+			default:
+				require.False(t, test.wantFired)
+				require.True(t, timer.Stop())
+				require.False(t, timer.Stop())
+			}
+		})
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	fake := NewFake(time.Now())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-fake.NewTimer(time.Hour).C()
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Hour)
+	<-done
+}
+
+func TestFakeBlockUntilContext(t *testing.T) {
+	fake := NewFake(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	require.ErrorIs(t, fake.BlockUntilContext(ctx, 1), context.Canceled)
+
+	fake.NewTimer(time.Hour)
+	require.NoError(t, fake.BlockUntilContext(context.Background(), 1))
+}
@@ -1,11 +1,13 @@
 module github.com/St0rmPetrel/handydandylib
 
-go 1.19
+go 1.20
 
 require (
 	github.com/stretchr/testify v1.8.1
 	golang.org/x/sync v0.1.0
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
 	google.golang.org/grpc v1.51.0
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
@@ -15,7 +17,5 @@ require (
 	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
 	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
 	golang.org/x/text v0.4.0 // indirect
-	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,81 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+// grpcStatus интерфейс ошибок, которые несут grpc статус
+type grpcStatus interface {
+	GRPCStatus() *status.Status
+}
+
+// callError ошибка вызова с grpc статусом, которая сохраняет цепочку ошибок
+// повторов. status.Code не разворачивает ошибки, поэтому статус отдается напрямую
+type callError struct {
+	status *status.Status
+	err    error
+}
+
+func (e *callError) Error() string { return e.err.Error() }
+
+func (e *callError) Unwrap() error { return e.err }
+
+// GRPCStatus возвращает статус вызова, его сообщение содержит всю ошибку
+func (e *callError) GRPCStatus() *status.Status { return e.status }
+
+// rejectedError ошибка попытки, которую отклонила разомкнутая цепь breaker-а.
+// Статуса у нее нет, что бы классификаторы grpc кодов не повторяли отклонения,
+// код Unavailable ей дает toCallError
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+
+func (e *rejectedError) Unwrap() error { return e.err }
+
+// toCallError приводит ошибку повторов к ошибке с grpc статусом: завершение
+// контекста вызова дает Canceled или DeadlineExceeded, отклонение последней
+// попытки цепью breaker-а - Unavailable, иначе берется статус из цепочки
+// (например последней попытки при ErrBudgetExhausted)
+func toCallError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		return &callError{
+			status: status.New(status.FromContextError(ctxErr).Code(), err.Error()),
+			err:    err,
+		}
+	}
+	if lastRejected(err) {
+		return &callError{status: status.New(codes.Unavailable, err.Error()), err: err}
+	}
+	if _, ok := err.(grpcStatus); ok {
+		return err
+	}
+	var withStatus grpcStatus
+	if errors.As(err, &withStatus) {
+		proto := withStatus.GRPCStatus().Proto()
+		proto.Message = err.Error()
+		return &callError{status: status.FromProto(proto), err: err}
+	}
+	return err
+}
+
+// lastRejected отклонена ли цепью breaker-а последняя попытка вызова
+func lastRejected(err error) bool {
+	var history *retry.Error
+	if errors.As(err, &history) {
+		err = history.Last()
+	}
+	var rejected *rejectedError
+	return errors.As(err, &rejected)
+}
@@ -0,0 +1,165 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/St0rmPetrel/handydandylib/breaker"
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+// UnaryClient конструктор grpc.UnaryClientInterceptor-а, который выполняет вызов
+// с повторами по политике retry, а каждую попытку пропускает через цепь breaker-а.
+// Дедлайн и отмена контекста вызова прерывают повторы, grpc.CallOption передаются
+// в каждую попытку без изменений. Вызов, последнюю попытку которого отклонила
+// цепь, завершается с кодом Unavailable. Цепи ключей хранятся в breaker.Group
+// и удаляются после простоя
+func UnaryClient(opts ...Option) grpc.UnaryClientInterceptor {
+	config := newDefaultOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	circuits := newCircuits(config)
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		key := config.breakerKey(cc.Target(), method)
+		err := callPolicy(config.retryPolicy, callOpts).Do(ctx, func(ctx context.Context) error {
+			return execute(ctx, circuits, key, func(ctx context.Context) error {
+				return invoker(ctx, method, req, reply, cc, callOpts...)
+			})
+		})
+		return toCallError(ctx, err)
+	}
+}
+
+// StreamClient конструктор grpc.StreamClientInterceptor-а, который с повторами
+// и через цепь breaker-а устанавливает поток. Ошибки уже установленного потока
+// не повторяются и не учитываются breaker-ом. retry.WithAttemptTimeout ограничивает
+// только установку потока, установленный поток живет в контексте вызова
+func StreamClient(opts ...Option) grpc.StreamClientInterceptor {
+	config := newDefaultOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	circuits := newCircuits(config)
+
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		key := config.breakerKey(cc.Target(), method)
+		stream, err := retry.DoValueWithPolicy(
+			ctx,
+			callPolicy(config.retryPolicy, callOpts),
+			func(attemptCtx context.Context) (grpc.ClientStream, error) {
+				var stream grpc.ClientStream
+				err := execute(attemptCtx, circuits, key, func(attemptCtx context.Context) (err error) {
+					stream, err = newStream(ctx, attemptCtx, func(ctx context.Context) (grpc.ClientStream, error) {
+						return streamer(ctx, desc, cc, method, callOpts...)
+					})
+					return err
+				})
+				return stream, err
+			},
+		)
+		return stream, toCallError(ctx, err)
+	}
+}
+
+// newStream устанавливает поток в контексте, производном от контекста вызова ctx,
+// а не попытки attemptCtx, который отменяется сразу после попытки. Пока поток
+// устанавливается, завершение attemptCtx прерывает установку
+func newStream(
+	ctx, attemptCtx context.Context,
+	streamer func(context.Context) (grpc.ClientStream, error),
+) (grpc.ClientStream, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	established := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-attemptCtx.Done():
+			cancel()
+		case <-established:
+		}
+	}()
+
+	stream, err := streamer(streamCtx)
+	close(established)
+	<-stopped
+	if err == nil && streamCtx.Err() != nil {
+		// попытка завершилась одновременно с установкой потока
+		err = attemptCtx.Err()
+	}
+	if err != nil || stream == nil {
+		cancel()
+		return stream, err
+	}
+	return &clientStream{ClientStream: stream, cancel: cancel}, nil
+}
+
+// clientStream поток, который освобождает свой контекст после завершения
+type clientStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// RecvMsg получает сообщение потока, ошибка (в том числе io.EOF) означает
+// что поток завершен
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// circuitCall попытка вызова, которую набор цепей выполняет через цепь ключа key
+type circuitCall struct {
+	key    string
+	invoke func(context.Context) error
+}
+
+// newCircuits набор цепей breaker-а по ключам вызовов
+func newCircuits(config *options) *breaker.Group[string, struct{}, circuitCall] {
+	return breaker.NewGroup(
+		func(call circuitCall) string { return call.key },
+		func(ctx context.Context, call circuitCall) (struct{}, error) {
+			return struct{}{}, call.invoke(ctx)
+		},
+		breaker.WithGroupBreakerOptions(config.breakerOptions...),
+		breaker.WithGroupKeyOptions(config.breakerKeyOptions),
+	)
+}
+
+// execute выполняет попытку через цепь ключа key. Ошибка вызова, отклоненного
+// цепью, оборачивается в rejectedError
+func execute(
+	ctx context.Context,
+	circuits *breaker.Group[string, struct{}, circuitCall],
+	key string,
+	invoke func(context.Context) error,
+) error {
+	called := false
+	_, err := circuits.Handle(ctx, circuitCall{key: key, invoke: func(ctx context.Context) error {
+		called = true
+		return invoke(ctx)
+	}})
+	if err != nil && !called {
+		return &rejectedError{err: err}
+	}
+	return err
+}
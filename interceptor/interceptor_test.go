@@ -0,0 +1,248 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/St0rmPetrel/handydandylib/breaker"
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+var errUnreachable = errors.New("unreachable")
+
+// flakyHealthServer health сервер, который отвечает ошибкой первые failures вызовов
+type flakyHealthServer struct {
+	healthpb.UnimplementedHealthServer
+
+	mu       sync.Mutex
+	calls    int
+	failures int
+	code     codes.Code
+}
+
+func (s *flakyHealthServer) Check(
+	context.Context,
+	*healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, status.Error(s.code, "flaky")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *flakyHealthServer) Watch(
+	_ *healthpb.HealthCheckRequest,
+	stream healthpb.Health_WatchServer,
+) error {
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+// dialBufconn поднимает in-process сервер и возвращает клиента к нему
+func dialBufconn(t *testing.T, server healthpb.HealthServer, dialOpts ...grpc.DialOption) healthpb.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		append(
+			dialOpts,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)...,
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputFailures int
+		inputCode     codes.Code
+		inputCallOpts []grpc.CallOption
+		wantCode      codes.Code
+		wantCalls     int
+	}{
+		{
+			name:          "retry_unavailable",
+			inputFailures: 2,
+			inputCode:     codes.Unavailable,
+			wantCode:      codes.OK,
+			wantCalls:     3,
+		},
+		{
+			name:          "no_retry_invalid_argument",
+			inputFailures: 2,
+			inputCode:     codes.InvalidArgument,
+			wantCode:      codes.InvalidArgument,
+			wantCalls:     1,
+		},
+		{
+			name:          "call_retry_options",
+			inputFailures: 2,
+			inputCode:     codes.Unavailable,
+			inputCallOpts: []grpc.CallOption{WithCallRetryOptions(retry.WithRetryCount(0))},
+			wantCode:      codes.Unavailable,
+			wantCalls:     1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &flakyHealthServer{failures: test.inputFailures, code: test.inputCode}
+			client := dialBufconn(t, server, grpc.WithUnaryInterceptor(UnaryClient(
+				WithRetryOptions(retry.WithRetryDelay(time.Millisecond)),
+			)))
+
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, test.inputCallOpts...)
+			require.Equal(t, test.wantCode, status.Code(err))
+			require.Equal(t, test.wantCalls, server.calls)
+		})
+	}
+}
+
+func TestUnaryClientBreaker(t *testing.T) {
+	server := &flakyHealthServer{failures: 100, code: codes.Unavailable}
+	client := dialBufconn(t, server, grpc.WithUnaryInterceptor(UnaryClient(
+		WithRetryOptions(retry.WithRetryCount(5), retry.WithRetryDelay(0)),
+		WithBreakerOptions(
+			breaker.WithFailureThreshold(1),
+			breaker.WithBreakDelay(time.Hour),
+			breaker.WithUnreachableError(errUnreachable),
+		),
+	)))
+
+	// две неудачные попытки размыкают цепь, третья отклоняется и не повторяется
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.ErrorIs(t, err, errUnreachable)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 2, server.calls)
+
+	// цепь общая для всех вызовов метода
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.ErrorIs(t, err, errUnreachable)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 2, server.calls)
+}
+
+func TestUnaryClientDeadline(t *testing.T) {
+	server := &flakyHealthServer{failures: 100, code: codes.Unavailable}
+	client := dialBufconn(t, server, grpc.WithUnaryInterceptor(UnaryClient(
+		WithRetryOptions(retry.WithRetryCount(100), retry.WithRetryDelay(time.Hour)),
+	)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, 1, server.calls)
+}
+
+func TestStreamClient(t *testing.T) {
+	server := &flakyHealthServer{}
+	client := dialBufconn(t, server, grpc.WithStreamInterceptor(StreamClient()))
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestUnaryClientBudgetExhausted(t *testing.T) {
+	server := &flakyHealthServer{failures: 100, code: codes.Unavailable}
+	client := dialBufconn(t, server, grpc.WithUnaryInterceptor(UnaryClient(
+		WithRetryOptions(retry.WithRetryDelay(0), retry.WithBudget(retry.NewBudget(0, 0))),
+	)))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.ErrorIs(t, err, retry.ErrBudgetExhausted)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 1, server.calls)
+}
+
+func TestStreamClientAttemptTimeout(t *testing.T) {
+	server := &flakyHealthServer{}
+	client := dialBufconn(t, server, grpc.WithStreamInterceptor(StreamClient(
+		WithRetryOptions(retry.WithAttemptTimeout(time.Second)),
+	)))
+
+	// поток живет дольше попытки, которая его установила
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestStreamClientRetry(t *testing.T) {
+	interceptor := StreamClient(
+		WithRetryOptions(retry.WithRetryDelay(0)),
+		WithBreakerOptions(breaker.WithFailureThreshold(100)),
+	)
+
+	calls := 0
+	_, err := interceptor(
+		context.Background(),
+		&grpc.StreamDesc{ServerStreams: true},
+		&grpc.ClientConn{},
+		"/test/Stream",
+		func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls++
+			if calls < 3 {
+				return nil, status.Error(codes.Unavailable, "flaky")
+			}
+			return nil, nil
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestUnaryClientBreakerKeyOptions(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+	server := &flakyHealthServer{failures: 1, code: codes.Unavailable}
+	client := dialBufconn(t, server, grpc.WithUnaryInterceptor(UnaryClient(
+		WithRetryOptions(retry.WithRetryCount(0)),
+		WithBreakerOptions(breaker.WithFailureThreshold(0), breaker.WithBreakDelay(time.Hour)),
+		WithBreakerKeyOptions(func(key string) []breaker.Option {
+			return []breaker.Option{breaker.WithStateChangeCallback(func(from, to breaker.State) {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, key+": "+from.String()+"->"+to.String())
+			})}
+		}),
+	)))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, []string{"/grpc.health.v1.Health/Check: closed->open"}, changes)
+}
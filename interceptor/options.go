@@ -0,0 +1,99 @@
+package interceptor
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/St0rmPetrel/handydandylib/breaker"
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+// Option функция для изменения настроек interceptor-ов
+type Option func(o *options)
+
+type options struct {
+	retryPolicy       retry.Policy
+	breakerOptions    []breaker.Option
+	breakerKeyOptions func(key string) []breaker.Option
+	breakerKey        KeyFunc
+}
+
+// KeyFunc функция, которая по target-у соединения и полному имени метода
+// возвращает ключ, каждому ключу соответствует своя цепь breaker-а
+type KeyFunc func(target, method string) string
+
+// PerMethod отдельная цепь на каждый метод (по умолчанию)
+func PerMethod(_, method string) string { return method }
+
+// PerTarget отдельная цепь на каждый target соединения
+func PerTarget(target, _ string) string { return target }
+
+// newDefaultOptions конструктор настроек по умолчанию:
+// повторяются только ошибки с кодами retry.DefaultGRPCRetryableCodes
+func newDefaultOptions() *options {
+	return &options{
+		retryPolicy:       retry.NewPolicy(retry.WithRetryIf(retry.GRPCRetryIf)),
+		breakerKeyOptions: func(_ string) []breaker.Option { return nil },
+		breakerKey:        PerMethod,
+	}
+}
+
+// WithRetryPolicy настройка политики повторов
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithRetryOptions настройка политики повторов через retry.Option,
+// применяется поверх текущей политики
+func WithRetryOptions(opts ...retry.Option) Option {
+	return func(o *options) {
+		o.retryPolicy = o.retryPolicy.With(opts...)
+	}
+}
+
+// WithBreakerOptions настройка цепей breaker-а, одинаковая для всех ключей
+func WithBreakerOptions(opts ...breaker.Option) Option {
+	return func(o *options) {
+		o.breakerOptions = append(o.breakerOptions, opts...)
+	}
+}
+
+// WithBreakerKeyOptions настройка функции, которая возвращает настройки цепи
+// breaker-а по ее ключу, например metrics.BreakerOptions с ключом в имени.
+// Применяются после WithBreakerOptions при создании цепи ключа
+func WithBreakerKeyOptions(keyOptions func(key string) []breaker.Option) Option {
+	return func(o *options) {
+		o.breakerKeyOptions = keyOptions
+	}
+}
+
+// WithBreakerKey настройка разбиения вызовов по цепям breaker-а (PerMethod, PerTarget
+// или своя функция)
+func WithBreakerKey(key KeyFunc) Option {
+	return func(o *options) {
+		o.breakerKey = key
+	}
+}
+
+// callRetryOption grpc.CallOption с настройками повторов конкретного вызова
+type callRetryOption struct {
+	grpc.EmptyCallOption
+	opts []retry.Option
+}
+
+// WithCallRetryOptions grpc.CallOption, который меняет политику повторов
+// для одного вызова, например WithCallRetryOptions(retry.WithRetryCount(0))
+func WithCallRetryOptions(opts ...retry.Option) grpc.CallOption {
+	return callRetryOption{opts: opts}
+}
+
+// callPolicy политика повторов вызова с учетом WithCallRetryOptions
+func callPolicy(policy retry.Policy, callOpts []grpc.CallOption) retry.Policy {
+	for _, callOpt := range callOpts {
+		if retryOpt, ok := callOpt.(callRetryOption); ok {
+			policy = policy.With(retryOpt.opts...)
+		}
+	}
+	return policy
+}
@@ -0,0 +1,135 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// Sample результат одного вызова, по которому алгоритм пересчитывает лимит
+type Sample struct {
+	// RTT длительность вызова
+	RTT time.Duration
+	// InFlight количество вызовов в полете в момент начала вызова (включая его)
+	InFlight int
+	// Dropped вызов завершился ошибкой, говорящей о перегрузке
+	Dropped bool
+}
+
+// Algorithm алгоритм пересчета лимита. Limiter вызывает Update последовательно,
+// поэтому реализация может хранить состояние без синхронизации
+type Algorithm interface {
+	// Update возвращает новый лимит по текущему лимиту и результату вызова
+	Update(limit int, sample Sample) int
+}
+
+// DefaultAIMDBackoffRatio множитель уменьшения лимита AIMD по умолчанию
+const DefaultAIMDBackoffRatio = 0.9
+
+// AIMD алгоритм additive increase / multiplicative decrease: лимит растет на 1
+// после успешного вызова, если лимит используется хотя бы наполовину, и умножается
+// на backoffRatio после вызова с ошибкой или дольше timeout. Вызовы, начатые при
+// большем лимите, лимит не уменьшают, поэтому всплеск ошибок уменьшает его один раз
+type AIMD struct {
+	backoffRatio float64
+	timeout      time.Duration
+}
+
+// NewAIMD конструктор алгоритма AIMD, backoffRatio строго между 0 и 1, иначе
+// используется DefaultAIMDBackoffRatio (timeout 0 - не учитывать длительность вызовов)
+func NewAIMD(backoffRatio float64, timeout time.Duration) *AIMD {
+	if !(backoffRatio > 0 && backoffRatio < 1) {
+		backoffRatio = DefaultAIMDBackoffRatio
+	}
+	return &AIMD{
+		backoffRatio: backoffRatio,
+		timeout:      timeout,
+	}
+}
+
+// Update пересчитывает лимит по результату вызова
+func (a *AIMD) Update(limit int, sample Sample) int {
+	if sample.Dropped || a.timeout > 0 && sample.RTT > a.timeout {
+		if sample.InFlight > limit {
+			return limit
+		}
+		return int(float64(limit) * a.backoffRatio)
+	}
+	if sample.InFlight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// Настройки алгоритма Gradient по умолчанию
+const (
+	DefaultGradientTolerance  = 1.5
+	DefaultGradientLongWindow = 600
+	DefaultGradientSmoothing  = 0.2
+)
+
+// Gradient алгоритм по мотивам gradient2 из Netflix concurrency-limits.
+// Сравнивает длительность вызова с долгосрочной средней длительностью: пока
+// вызовы не медленнее средней больше чем в tolerance раз, лимит растет на
+// корень из лимита (допустимая очередь), при росте длительности лимит уменьшается
+// пропорционально, но не больше чем вдвое за вызов
+type Gradient struct {
+	tolerance  float64
+	longWindow float64
+	smoothing  float64
+
+	estimated float64
+	longRTT   float64
+}
+
+// NewGradient конструктор алгоритма Gradient. tolerance допустимое отношение
+// длительности вызова к долгосрочной средней (не меньше 1), longWindow количество
+// вызовов, за которое усредняется долгосрочная длительность (не меньше 1).
+// Меньшие значения заменяются на 1
+func NewGradient(tolerance float64, longWindow uint) *Gradient {
+	if !(tolerance >= 1) {
+		tolerance = 1
+	}
+	if longWindow < 1 {
+		longWindow = 1
+	}
+	return &Gradient{
+		tolerance:  tolerance,
+		longWindow: float64(longWindow),
+		smoothing:  DefaultGradientSmoothing,
+	}
+}
+
+// Update пересчитывает лимит по результату вызова
+func (g *Gradient) Update(limit int, sample Sample) int {
+	// лимит мог быть ограничен Limiter-ом, оценка продолжается от него
+	if int(g.estimated) != limit {
+		g.estimated = float64(limit)
+	}
+
+	rtt := float64(sample.RTT)
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		g.longRTT += (rtt - g.longRTT) / g.longWindow
+	}
+	// после перегрузки долгосрочная длительность быстро возвращается к текущей
+	if g.longRTT/rtt > 2 {
+		g.longRTT *= 0.95
+	}
+	// лимит используется меньше чем наполовину, длительность о нем ничего не говорит
+	if sample.InFlight*2 < limit && !sample.Dropped {
+		return limit
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.tolerance*g.longRTT/math.Max(rtt, 1)))
+	if sample.Dropped {
+		// вызовы, начатые при большем лимите, уже учтены
+		if sample.InFlight > limit {
+			return limit
+		}
+		gradient = 0.5
+	}
+	newLimit := g.estimated*gradient + math.Sqrt(g.estimated)
+	g.estimated = g.estimated*(1-g.smoothing) + newLimit*g.smoothing
+	return int(g.estimated)
+}
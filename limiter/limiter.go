@@ -0,0 +1,249 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// ErrLimitExceeded ошибка, которую возвращает Limiter, если количество
+// одновременных вызовов достигло текущего лимита
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+// errPanicked результат вызова, fn которого запаниковала, всегда считается перегрузкой
+var errPanicked = errors.New("limiter: handler panicked")
+
+// Limiter адаптивный ограничитель количества одновременных вызовов.
+// Лимит пересчитывается алгоритмом (AIMD, Gradient) после каждого вызова
+// по его длительности и ошибке, вызовы сверх лимита сразу отклоняются.
+// Безопасен для конкурентного использования
+type Limiter struct {
+	config *options
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+}
+
+// New конструктор адаптивного ограничителя
+func New(opts ...Option) *Limiter {
+	config := newDefaultOptions()
+	for _, opt := range opts {
+		opt(config)
+	}
+	// при нулевом лимите не завершается ни один вызов и лимит больше не пересчитывается
+	if config.minLimit < 1 {
+		config.minLimit = 1
+	}
+	if config.maxLimit < config.minLimit {
+		config.maxLimit = config.minLimit
+	}
+	return &Limiter{
+		config: config,
+		limit:  config.clamp(config.initialLimit),
+	}
+}
+
+// Wrap wraps handler with a Limiter, so at most Limit() calls of handler run
+// concurrently. Calls over the limit are rejected with ErrLimitExceeded without
+// calling handler. The limit adapts to the latency and errors of handler calls.
+func Wrap[RespT, ReqT any](
+	l *Limiter,
+	handler func(context.Context, ReqT) (RespT, error),
+) func(context.Context, ReqT) (RespT, error) {
+	return func(ctx context.Context, req ReqT) (RespT, error) {
+		var resp RespT
+		err := l.Execute(ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// Execute выполняет fn, если текущий лимит позволяет, иначе возвращает ErrLimitExceeded.
+// Паника fn учитывается как ошибка перегрузки и продолжается
+func (l *Limiter) Execute(ctx context.Context, fn func(context.Context) error) (err error) {
+	inFlight, ok := l.acquire()
+	if !ok {
+		return ErrLimitExceeded
+	}
+
+	start := l.config.clock.Now()
+	panicked := true
+	defer func() {
+		if panicked {
+			err = errPanicked
+		}
+		l.release(inFlight, l.config.clock.Now().Sub(start), err)
+	}()
+
+	err = fn(ctx)
+	panicked = false
+	return err
+}
+
+// Limit возвращает текущий лимит одновременных вызовов
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+// InFlight возвращает количество выполняющихся сейчас вызовов
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+// acquire занимает место под вызов и возвращает количество вызовов
+// в полете вместе с ним
+func (l *Limiter) acquire() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= l.limit {
+		return 0, false
+	}
+	l.inFlight++
+	return l.inFlight, true
+}
+
+// release освобождает место вызова и пересчитывает лимит по его результату.
+// Вызовы, отмененные вызывающим, не влияют на лимит
+func (l *Limiter) release(inFlight int, rtt time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	limit := l.config.algorithm.Update(l.limit, Sample{
+		RTT:      rtt,
+		InFlight: inFlight,
+		Dropped:  err == errPanicked || err != nil && l.config.dropClassifier(err),
+	})
+	limit = l.config.clamp(limit)
+	if limit != l.limit {
+		from := l.limit
+		l.limit = limit
+		l.config.limitChangeCallback(from, limit)
+	}
+}
+
+// Option функция для изменения настроек поведения Limiter-а
+type Option func(o *options)
+
+type options struct {
+	algorithm           Algorithm
+	initialLimit        int
+	minLimit            int
+	maxLimit            int
+	dropClassifier      func(error) bool
+	limitChangeCallback func(from, to int)
+	clock               clock.Clock
+}
+
+// Настройки Limiter-а по умолчанию
+var (
+	defaultInitialLimit        = 20
+	defaultMinLimit            = 1
+	defaultMaxLimit            = 1000
+	defaultLimitChangeCallback = func(_, _ int) {}
+)
+
+// DefaultDropClassifier классификатор ошибок по умолчанию: любая ошибка
+// говорит о перегрузке
+func DefaultDropClassifier(_ error) bool { return true }
+
+// newDefaultOptions конструктор настроек по умолчанию
+func newDefaultOptions() *options {
+	return &options{
+		algorithm:           NewAIMD(DefaultAIMDBackoffRatio, 0),
+		initialLimit:        defaultInitialLimit,
+		minLimit:            defaultMinLimit,
+		maxLimit:            defaultMaxLimit,
+		dropClassifier:      DefaultDropClassifier,
+		limitChangeCallback: defaultLimitChangeCallback,
+		clock:               clock.New(),
+	}
+}
+
+// clamp ограничивает лимит значениями minLimit и maxLimit
+func (o *options) clamp(limit int) int {
+	if limit < o.minLimit {
+		return o.minLimit
+	}
+	if limit > o.maxLimit {
+		return o.maxLimit
+	}
+	return limit
+}
+
+// WithAlgorithm настройка алгоритма пересчета лимита (по умолчанию AIMD).
+// Алгоритм не должен использоваться несколькими Limiter-ами
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithInitialLimit настройка начального лимита
+func WithInitialLimit(limit int) Option {
+	return func(o *options) {
+		o.initialLimit = limit
+	}
+}
+
+// WithMinLimit настройка минимального лимита (не меньше 1, меньшие значения заменяются на 1)
+func WithMinLimit(limit int) Option {
+	return func(o *options) {
+		o.minLimit = limit
+	}
+}
+
+// WithMaxLimit настройка максимального лимита (не меньше минимального,
+// меньшие значения заменяются на минимальный)
+func WithMaxLimit(limit int) Option {
+	return func(o *options) {
+		o.maxLimit = limit
+	}
+}
+
+// WithDropClassifier настройка классификатора ошибок: ошибка считается признаком
+// перегрузки и уменьшает лимит только если classifier вернул true
+// (по умолчанию DefaultDropClassifier)
+func WithDropClassifier(classifier func(error) bool) Option {
+	return func(o *options) {
+		o.dropClassifier = classifier
+	}
+}
+
+// WithLimitChangeCallback настройка функции callback вызываемой при изменении
+// лимита (обычно используется для логов и метрик). Добавляется к заданным
+// ранее callback-ам и вызывается после них
+func WithLimitChangeCallback(callback func(from, to int)) Option {
+	return func(o *options) {
+		prev := o.limitChangeCallback
+		o.limitChangeCallback = func(from, to int) {
+			prev(from, to)
+			callback(from, to)
+		}
+	}
+}
+
+// WithClock настройка источника времени (используется в тестах)
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
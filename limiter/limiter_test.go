@@ -0,0 +1,162 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	var changes [][2]int
+	l := New(
+		WithInitialLimit(1),
+		WithAlgorithm(NewAIMD(0.5, 0)),
+		WithLimitChangeCallback(func(from, to int) {
+			changes = append(changes, [2]int{from, to})
+		}),
+	)
+	errHandler := errors.New("handler")
+	release := make(chan error)
+	started := make(chan struct{})
+	handler := Wrap(l, func(_ context.Context, req int) (int, error) {
+		close(started)
+		return req, <-release
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := handler(context.Background(), 1)
+		done <- err
+	}()
+	<-started
+	require.Equal(t, 1, l.InFlight())
+
+	_, err := handler(context.Background(), 2)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	release <- nil
+	require.NoError(t, <-done)
+	require.Equal(t, 2, l.Limit())
+
+	require.ErrorIs(t, l.Execute(context.Background(), func(context.Context) error { return errHandler }), errHandler)
+	require.Equal(t, 1, l.Limit())
+
+	// вызовы, отмененные вызывающим, не влияют на лимит
+	require.ErrorIs(t, l.Execute(context.Background(), func(context.Context) error { return context.Canceled }), context.Canceled)
+	require.Equal(t, 1, l.Limit())
+	require.Equal(t, [][2]int{{1, 2}, {2, 1}}, changes)
+}
+
+func TestLimiterPanic(t *testing.T) {
+	l := New(WithInitialLimit(1), WithAlgorithm(NewAIMD(0.5, 0)), WithMinLimit(1))
+
+	func() {
+		defer func() {
+			require.Equal(t, "boom", recover())
+		}()
+		_ = l.Execute(context.Background(), func(context.Context) error { panic("boom") })
+	}()
+	require.Equal(t, 0, l.InFlight())
+	require.NoError(t, l.Execute(context.Background(), func(context.Context) error { return nil }))
+}
+
+func TestLimiterInvalidLimits(t *testing.T) {
+	errHandler := errors.New("handler")
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "zero_min", opts: []Option{WithMinLimit(0)}},
+		{name: "zero_max", opts: []Option{WithMaxLimit(0)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := New(append([]Option{WithInitialLimit(1), WithAlgorithm(NewAIMD(0.5, 0))}, test.opts...)...)
+
+			require.ErrorIs(t, l.Execute(context.Background(), func(context.Context) error { return errHandler }), errHandler)
+			require.Equal(t, 1, l.Limit())
+			require.NoError(t, l.Execute(context.Background(), func(context.Context) error { return nil }))
+		})
+	}
+}
+
+func TestAIMDInvalidBackoffRatio(t *testing.T) {
+	for _, ratio := range []float64{0, -1, 1, 2} {
+		aimd := NewAIMD(ratio, 0)
+		require.Equal(t, 9, aimd.Update(10, Sample{InFlight: 10, Dropped: true}), "ratio %v", ratio)
+	}
+}
+
+func TestGradientInvalidParams(t *testing.T) {
+	g := NewGradient(0, 0)
+	limit := 10
+	for i := 0; i < 100; i++ {
+		limit = g.Update(limit, Sample{RTT: time.Millisecond, InFlight: limit})
+	}
+	require.Greater(t, limit, 10)
+}
+
+func TestAIMD(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		sample Sample
+		want   int
+	}{
+		{name: "increase", limit: 10, sample: Sample{RTT: time.Millisecond, InFlight: 5}, want: 11},
+		{name: "underused", limit: 10, sample: Sample{RTT: time.Millisecond, InFlight: 4}, want: 10},
+		{name: "drop", limit: 10, sample: Sample{InFlight: 10, Dropped: true}, want: 5},
+		{name: "timeout", limit: 10, sample: Sample{RTT: time.Second, InFlight: 10}, want: 5},
+		{name: "stale_drop", limit: 10, sample: Sample{InFlight: 11, Dropped: true}, want: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			aimd := NewAIMD(0.5, 100*time.Millisecond)
+			require.Equal(t, test.want, aimd.Update(test.limit, test.sample))
+		})
+	}
+}
+
+func TestSimulation(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm func() Algorithm
+	}{
+		{name: "aimd", algorithm: func() Algorithm { return NewAIMD(DefaultAIMDBackoffRatio, 0) }},
+		{name: "aimd_timeout", algorithm: func() Algorithm { return NewAIMD(DefaultAIMDBackoffRatio, 15*time.Millisecond) }},
+		{name: "gradient", algorithm: func() Algorithm {
+			return NewGradient(DefaultGradientTolerance, DefaultGradientLongWindow)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &simulation{
+				limiter:  New(WithAlgorithm(test.algorithm())),
+				tick:     time.Millisecond,
+				arrivals: 10,
+			}
+
+			// нагрузка превышает пропускную способность, лимит должен установиться
+			// между половиной емкости сервиса и удвоенным порогом ошибок перегрузки
+			// и следовать за изменением емкости
+			const overload = 2
+			var means []float64
+			for _, capacity := range []int{40, 80, 20} {
+				limits, rejected := s.run(backend{latency: 10 * time.Millisecond, capacity: capacity, overload: overload}, 5000)
+				mean := meanLimit(limits[len(limits)/2:])
+				require.Greater(t, rejected, 0, "capacity %d", capacity)
+				require.GreaterOrEqual(t, mean, float64(capacity)/2, "capacity %d", capacity)
+				require.LessOrEqual(t, mean, 2*overload*float64(capacity), "capacity %d", capacity)
+				means = append(means, mean)
+			}
+			require.Greater(t, means[1], means[0])
+			require.Less(t, means[2], means[0])
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var errOverload = errors.New("overload")
+
+// backend модель сервиса: пока вызовов в полете не больше capacity, вызов длится
+// latency, дальше длительность растет пропорционально очереди, а при превышении
+// capacity в overload раз вызовы завершаются ошибкой
+type backend struct {
+	latency  time.Duration
+	capacity int
+	overload float64
+}
+
+func (b backend) call(inFlight int) (time.Duration, error) {
+	load := float64(inFlight) / float64(b.capacity)
+	if b.overload > 0 && load > b.overload {
+		return b.latency, errOverload
+	}
+	if load < 1 {
+		load = 1
+	}
+	return time.Duration(float64(b.latency) * load), nil
+}
+
+// completion вызов, который завершится в момент at
+type completion struct {
+	at       time.Duration
+	inFlight int
+	rtt      time.Duration
+	err      error
+}
+
+// simulation детерминированная симуляция нагрузки на Limiter в дискретном времени:
+// каждый tick приходит arrivals вызовов к backend
+type simulation struct {
+	limiter  *Limiter
+	tick     time.Duration
+	arrivals int
+
+	now     time.Duration
+	pending []completion
+}
+
+// run симулирует ticks тиков и возвращает лимит после каждого тика
+// и количество отклоненных вызовов
+func (s *simulation) run(b backend, ticks int) (limits []int, rejected int) {
+	for i := 0; i < ticks; i++ {
+		s.now += s.tick
+
+		sort.SliceStable(s.pending, func(i, j int) bool { return s.pending[i].at < s.pending[j].at })
+		done := 0
+		for _, c := range s.pending {
+			if c.at > s.now {
+				break
+			}
+			s.limiter.release(c.inFlight, c.rtt, c.err)
+			done++
+		}
+		s.pending = s.pending[done:]
+
+		for j := 0; j < s.arrivals; j++ {
+			inFlight, ok := s.limiter.acquire()
+			if !ok {
+				rejected++
+				continue
+			}
+			rtt, err := b.call(inFlight)
+			s.pending = append(s.pending, completion{at: s.now + rtt, inFlight: inFlight, rtt: rtt, err: err})
+		}
+		limits = append(limits, s.limiter.Limit())
+	}
+	return limits, rejected
+}
+
+// meanLimit среднее значение лимита
+func meanLimit(limits []int) float64 {
+	var sum int
+	for _, limit := range limits {
+		sum += limit
+	}
+	return float64(sum) / float64(len(limits))
+}
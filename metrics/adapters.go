@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/batch"
+	"github.com/St0rmPetrel/handydandylib/breaker"
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+// RetryOptions настройки retry, которые записывают метрики попыток, повторов,
+// успехов и отказов с меткой name.
+// Добавляют callback-и WithBeforeAttemptCallback, WithBackoffCallback,
+// WithSuccessCallback и WithGiveUpCallback к заданным пользователем
+func RetryOptions(rec Recorder, name string) []retry.Option {
+	nameLabel := Label{Name: LabelName, Value: name}
+	return []retry.Option{
+		retry.WithBeforeAttemptCallback(func(_ uint) {
+			rec.AddCounter(RetryAttemptsTotal, 1, nameLabel)
+		}),
+		retry.WithBackoffCallback(func(_ uint, _ error, _ time.Duration) {
+			rec.AddCounter(RetryRetriesTotal, 1, nameLabel)
+		}),
+		retry.WithSuccessCallback(func(_ uint) {
+			rec.AddCounter(RetrySuccessesTotal, 1, nameLabel)
+		}),
+		retry.WithGiveUpCallback(func(_ uint, _ error) {
+			rec.AddCounter(RetryGiveUpsTotal, 1, nameLabel)
+		}),
+	}
+}
+
+// BreakerOptions настройки breaker, которые записывают метрики состояния цепи,
+// его смен и отклоненных запросов с меткой name.
+// Добавляют callback-и WithCreateCallback, WithStateChangeCallback и WithRejectCallback
+// к заданным пользователем
+func BreakerOptions(rec Recorder, name string) []breaker.Option {
+	nameLabel := Label{Name: LabelName, Value: name}
+	return []breaker.Option{
+		breaker.WithCreateCallback(func(state breaker.State) {
+			rec.SetGauge(BreakerState, float64(state), nameLabel)
+		}),
+		breaker.WithStateChangeCallback(func(from, to breaker.State) {
+			rec.AddCounter(BreakerTransitionsTotal, 1,
+				nameLabel,
+				Label{Name: LabelFrom, Value: from.String()},
+				Label{Name: LabelTo, Value: to.String()},
+			)
+			rec.SetGauge(BreakerState, float64(to), nameLabel)
+		}),
+		breaker.WithRejectCallback(func(_ breaker.State) {
+			rec.AddCounter(BreakerRejectionsTotal, 1, nameLabel)
+		}),
+	}
+}
+
+// BatchOptions настройки batch, которые записывают метрики размеров батчей,
+// длительности их обработки и ошибок с меткой name. size вычисляет размер батча по запросу.
+// Добавляют callback WithHandleCallback к заданным пользователем
+func BatchOptions[ReqT any](rec Recorder, name string, size func(ReqT) int) []batch.Option[ReqT] {
+	nameLabel := Label{Name: LabelName, Value: name}
+	return []batch.Option[ReqT]{
+		batch.WithHandleCallback(func(req ReqT, dur time.Duration, err error) {
+			rec.ObserveHistogram(BatchSize, float64(size(req)), nameLabel)
+			rec.ObserveHistogram(BatchLatencySeconds, dur.Seconds(), nameLabel)
+			if err != nil {
+				rec.AddCounter(BatchErrorsTotal, 1, nameLabel)
+			}
+		}),
+	}
+}
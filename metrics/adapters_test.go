@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/St0rmPetrel/handydandylib/batch"
+	"github.com/St0rmPetrel/handydandylib/breaker"
+	"github.com/St0rmPetrel/handydandylib/retry"
+)
+
+var errTest = errors.New("test")
+
+func TestRetryOptions(t *testing.T) {
+	rec := NewMemoryRecorder()
+	nameLabel := Label{Name: LabelName, Value: "backend"}
+	giveUps := 0
+	opts := append(
+		RetryOptions(rec, "backend"),
+		retry.WithGiveUpCallback(func(_ uint, _ error) { giveUps++ }),
+		retry.WithRetryCount(2),
+		retry.WithRetryDelay(0),
+	)
+
+	attempts := 0
+	require.NoError(t, retry.Do(func() error {
+		attempts++
+		if attempts < 2 {
+			return errTest
+		}
+		return nil
+	}, opts...))
+	require.Error(t, retry.Do(func() error { return errTest }, opts...))
+
+	require.Equal(t, 5.0, rec.Counter(RetryAttemptsTotal, nameLabel))
+	require.Equal(t, 3.0, rec.Counter(RetryRetriesTotal, nameLabel))
+	require.Equal(t, 1.0, rec.Counter(RetrySuccessesTotal, nameLabel))
+	require.Equal(t, 1.0, rec.Counter(RetryGiveUpsTotal, nameLabel))
+	require.Equal(t, 1, giveUps)
+}
+
+func TestBreakerOptions(t *testing.T) {
+	rec := NewMemoryRecorder()
+	nameLabel := Label{Name: LabelName, Value: "backend"}
+	var changes []string
+	logChange := func(prefix string) breaker.Option {
+		return breaker.WithStateChangeCallback(func(from, to breaker.State) {
+			changes = append(changes, prefix+": "+from.String()+"->"+to.String())
+		})
+	}
+
+	// до создания цепи метрика состояния не трогается
+	rec.SetGauge(BreakerState, -1, nameLabel)
+	opts := append(
+		append([]breaker.Option{logChange("before")}, BreakerOptions(rec, "backend")...),
+		logChange("after"),
+		breaker.WithFailureThreshold(0),
+		breaker.WithBreakDelay(time.Hour),
+	)
+	require.Equal(t, -1.0, rec.Gauge(BreakerState, nameLabel))
+
+	handler := breaker.Breaker(
+		func(context.Context, int) (int, error) { return 0, errTest },
+		opts...,
+	)
+
+	require.Equal(t, float64(breaker.StateClosed), rec.Gauge(BreakerState, nameLabel))
+	for i := 0; i < 3; i++ {
+		_, _ = handler(context.Background(), i)
+	}
+
+	require.Equal(t, float64(breaker.StateOpen), rec.Gauge(BreakerState, nameLabel))
+	require.Equal(t, 1.0, rec.Counter(BreakerTransitionsTotal,
+		nameLabel,
+		Label{Name: LabelFrom, Value: "closed"},
+		Label{Name: LabelTo, Value: "open"},
+	))
+	require.Equal(t, 2.0, rec.Counter(BreakerRejectionsTotal, nameLabel))
+	// callback-и пользователя сохраняются независимо от порядка настроек
+	require.Equal(t, []string{"before: closed->open", "after: closed->open"}, changes)
+}
+
+func TestBatchOptions(t *testing.T) {
+	rec := NewMemoryRecorder()
+	nameLabel := Label{Name: LabelName, Value: "sum"}
+
+	inputData := []int{1, 2, 3, 4, 5}
+	iterator := batch.NewSegmentIterator(0, len(inputData), 2)
+	handler := batch.NewGrpcHandler(
+		func(_ context.Context, req []int, _ ...grpc.CallOption) (int, error) {
+			if len(req) == 1 {
+				return 0, errTest
+			}
+			return 0, nil
+		},
+		func() ([]int, bool) {
+			from, to, ok := iterator.Next()
+			if !ok {
+				return nil, false
+			}
+			return inputData[from:to], true
+		},
+		BatchOptions(rec, "sum", func(req []int) int { return len(req) })...,
+	)
+
+	_, err := handler.DoSerial(context.Background())
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, []float64{2, 2, 1}, rec.Histogram(BatchSize, nameLabel))
+	require.Len(t, rec.Histogram(BatchLatencySeconds, nameLabel), 3)
+	require.Equal(t, 1.0, rec.Counter(BatchErrorsTotal, nameLabel))
+}
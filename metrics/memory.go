@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryRecorder Recorder, хранящий метрики в памяти (используется в тестах)
+type MemoryRecorder struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	histograms map[string][]float64
+	gauges     map[string]float64
+}
+
+// NewMemoryRecorder конструктор Recorder-а, хранящего метрики в памяти
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{
+		counters:   make(map[string]float64),
+		histograms: make(map[string][]float64),
+		gauges:     make(map[string]float64),
+	}
+}
+
+// AddCounter увеличивает счетчик name на delta
+func (r *MemoryRecorder) AddCounter(name string, delta float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[seriesKey(name, labels)] += delta
+}
+
+// ObserveHistogram записывает значение в гистограмму name
+func (r *MemoryRecorder) ObserveHistogram(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := seriesKey(name, labels)
+	r.histograms[key] = append(r.histograms[key], value)
+}
+
+// SetGauge устанавливает значение gauge name
+func (r *MemoryRecorder) SetGauge(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[seriesKey(name, labels)] = value
+}
+
+// Counter возвращает значение счетчика (порядок меток не важен)
+func (r *MemoryRecorder) Counter(name string, labels ...Label) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[seriesKey(name, labels)]
+}
+
+// Histogram возвращает все записанные в гистограмму значения (порядок меток не важен)
+func (r *MemoryRecorder) Histogram(name string, labels ...Label) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.histograms[seriesKey(name, labels)]...)
+}
+
+// Gauge возвращает значение gauge (порядок меток не важен)
+func (r *MemoryRecorder) Gauge(name string, labels ...Label) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gauges[seriesKey(name, labels)]
+}
+
+// seriesKey ключ временного ряда вида name{a="1",b="2"}
+func seriesKey(name string, labels []Label) string {
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, label := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label.Name)
+		b.WriteString(`="`)
+		b.WriteString(label.Value)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
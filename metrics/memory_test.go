@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRecorder(t *testing.T) {
+	rec := NewMemoryRecorder()
+	a, b := Label{Name: "a", Value: "1"}, Label{Name: "b", Value: "2"}
+
+	rec.AddCounter("requests", 1, a, b)
+	rec.AddCounter("requests", 2, b, a)
+	rec.AddCounter("requests", 5)
+	rec.ObserveHistogram("latency", 0.5, a)
+	rec.ObserveHistogram("latency", 1.5, a)
+	rec.SetGauge("state", 1, a)
+	rec.SetGauge("state", 2, a)
+
+	require.Equal(t, 3.0, rec.Counter("requests", a, b))
+	require.Equal(t, 5.0, rec.Counter("requests"))
+	require.Equal(t, 0.0, rec.Counter("requests", a))
+	require.Equal(t, []float64{0.5, 1.5}, rec.Histogram("latency", a))
+	require.Empty(t, rec.Histogram("latency"))
+	require.Equal(t, 2.0, rec.Gauge("state", a))
+}
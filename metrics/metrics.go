@@ -0,0 +1,54 @@
+package metrics
+
+// Label метка метрики в стиле Prometheus
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Recorder минимальный интерфейс записи метрик, который легко реализовать
+// поверх Prometheus или любой другой системы метрик
+type Recorder interface {
+	// AddCounter увеличивает счетчик name на delta
+	AddCounter(name string, delta float64, labels ...Label)
+	// ObserveHistogram записывает значение в гистограмму name
+	ObserveHistogram(name string, value float64, labels ...Label)
+	// SetGauge устанавливает значение gauge name
+	SetGauge(name string, value float64, labels ...Label)
+}
+
+// Имена метрик, которые записывают адаптеры пакета
+const (
+	// RetryAttemptsTotal счетчик попыток
+	RetryAttemptsTotal = "retry_attempts_total"
+	// RetryRetriesTotal счетчик повторов (попыток после неудачной)
+	RetryRetriesTotal = "retry_retries_total"
+	// RetrySuccessesTotal счетчик успешных вызовов
+	RetrySuccessesTotal = "retry_successes_total"
+	// RetryGiveUpsTotal счетчик вызовов, завершившихся ошибкой
+	RetryGiveUpsTotal = "retry_give_ups_total"
+
+	// BreakerState gauge текущего состояния цепи (значение breaker.State)
+	BreakerState = "breaker_state"
+	// BreakerTransitionsTotal счетчик смен состояния цепи (метки from и to)
+	BreakerTransitionsTotal = "breaker_transitions_total"
+	// BreakerRejectionsTotal счетчик запросов, отклоненных разомкнутой цепью
+	BreakerRejectionsTotal = "breaker_rejections_total"
+
+	// BatchSize гистограмма размеров батчей
+	BatchSize = "batch_size"
+	// BatchLatencySeconds гистограмма длительности обработки батча в секундах
+	BatchLatencySeconds = "batch_latency_seconds"
+	// BatchErrorsTotal счетчик батчей, обработанных с ошибкой
+	BatchErrorsTotal = "batch_errors_total"
+)
+
+// Имена меток, которые записывают адаптеры пакета
+const (
+	// LabelName имя обертки, переданное в адаптер
+	LabelName = "name"
+	// LabelFrom исходное состояние цепи
+	LabelFrom = "from"
+	// LabelTo новое состояние цепи
+	LabelTo = "to"
+)
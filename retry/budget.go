@@ -0,0 +1,136 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// ErrBudgetExhausted ошибка, которую возвращает Do если бюджет повторов исчерпан
+var ErrBudgetExhausted = errors.New("retry: budget exhausted")
+
+// Budget общий бюджет повторов, защищает от лавины повторов к перегруженному сервису.
+// Повторы разрешаются в количестве ratio от успешных вызовов плюс minRetriesPerSecond
+// в секунду, все в пределах скользящего окна ttl (как RetryBudget в Finagle).
+// Один бюджет может использоваться многими вызовами Do через WithBudget
+type Budget struct {
+	mu sync.Mutex
+
+	ratio        float64
+	minPerSecond uint
+	ttl          time.Duration
+	clock        clock.Clock
+	buckets      []budgetBucket
+}
+
+// budgetBucket счетчики бюджета за одну секунду
+type budgetBucket struct {
+	second      int64
+	deposits    uint
+	withdrawals uint
+}
+
+// BudgetOption функция для настройки Budget
+type BudgetOption func(opts *budgetOptions)
+
+type budgetOptions struct {
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// DefaultBudgetTTL длительность скользящего окна бюджета по умолчанию
+const DefaultBudgetTTL = 10 * time.Second
+
+// WithBudgetTTL настраивает длительность скользящего окна бюджета (округляется до секунд)
+func WithBudgetTTL(ttl time.Duration) BudgetOption {
+	return func(opts *budgetOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithBudgetClock настраивает источник времени бюджета (используется в тестах)
+func WithBudgetClock(clk clock.Clock) BudgetOption {
+	return func(opts *budgetOptions) {
+		opts.clock = clk
+	}
+}
+
+// NewBudget конструктор бюджета повторов: ratio доля повторов от успешных вызовов,
+// minRetriesPerSecond количество повторов в секунду, разрешенное в любом случае
+func NewBudget(ratio float64, minRetriesPerSecond uint, opts ...BudgetOption) *Budget {
+	budgetOpts := budgetOptions{
+		ttl:   DefaultBudgetTTL,
+		clock: clock.New(),
+	}
+	for _, opt := range opts {
+		opt(&budgetOpts)
+	}
+
+	seconds := int((budgetOpts.ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &Budget{
+		ratio:        ratio,
+		minPerSecond: minRetriesPerSecond,
+		ttl:          time.Duration(seconds) * time.Second,
+		clock:        budgetOpts.clock,
+		buckets:      make([]budgetBucket, seconds),
+	}
+}
+
+// Deposit учитывает успешный вызов, пополняя бюджет
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket().deposits++
+}
+
+// TryWithdraw пытается списать из бюджета один повтор,
+// возвращает false если бюджет исчерпан
+func (b *Budget) TryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.balance() < 1 {
+		return false
+	}
+	b.bucket().withdrawals++
+	return true
+}
+
+// Balance возвращает количество повторов, доступных прямо сейчас
+func (b *Budget) Balance() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.balance()
+}
+
+func (b *Budget) balance() int {
+	now := b.clock.Now().Unix()
+
+	var deposits, withdrawals uint
+	for _, bucket := range b.buckets {
+		if now-bucket.second >= int64(len(b.buckets)) {
+			continue
+		}
+		deposits += bucket.deposits
+		withdrawals += bucket.withdrawals
+	}
+	reserve := float64(b.minPerSecond) * b.ttl.Seconds()
+	return int(b.ratio*float64(deposits)+reserve) - int(withdrawals)
+}
+
+// bucket возвращает счетчики текущей секунды, сбрасывая устаревшие
+func (b *Budget) bucket() *budgetBucket {
+	now := b.clock.Now().Unix()
+	bucket := &b.buckets[now%int64(len(b.buckets))]
+	if bucket.second != now {
+		*bucket = budgetBucket{second: now}
+	}
+	return bucket
+}
@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+func TestBudget(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	budget := NewBudget(0.5, 1, WithBudgetTTL(2*time.Second), WithBudgetClock(fake))
+
+	// резерв minRetriesPerSecond * ttl
+	require.Equal(t, 2, budget.Balance())
+
+	for i := 0; i < 4; i++ {
+		budget.Deposit()
+	}
+	require.Equal(t, 4, budget.Balance())
+
+	for i := 0; i < 4; i++ {
+		require.True(t, budget.TryWithdraw())
+	}
+	require.False(t, budget.TryWithdraw())
+	require.Equal(t, 0, budget.Balance())
+
+	// пополнения и списания выходят из окна
+	fake.Advance(time.Second)
+	budget.Deposit()
+	budget.Deposit()
+	require.Equal(t, 1, budget.Balance())
+	fake.Advance(time.Second)
+	require.Equal(t, 3, budget.Balance())
+	fake.Advance(time.Second)
+	require.Equal(t, 2, budget.Balance())
+}
+
+func TestDoBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputBudget   *Budget
+		inputFailures int
+		wantAttempts  int
+		wantErr       bool
+		wantBudgetErr bool
+	}{
+		{
+			name:          "exhausted",
+			inputBudget:   NewBudget(0, 0),
+			inputFailures: 10,
+			wantAttempts:  1,
+			wantErr:       true,
+			wantBudgetErr: true,
+		},
+		{
+			name:          "retry_count_exceeded",
+			inputBudget:   NewBudget(0, 1),
+			inputFailures: 10,
+			wantAttempts:  4,
+			wantErr:       true,
+			wantBudgetErr: false,
+		},
+		{
+			name:          "success",
+			inputBudget:   NewBudget(0, 1),
+			inputFailures: 2,
+			wantAttempts:  3,
+			wantErr:       false,
+			wantBudgetErr: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := Do(
+				func() error {
+					attempts++
+					if attempts <= test.inputFailures {
+						return fmt.Errorf("")
+					}
+					return nil
+				},
+				WithRetryCount(3),
+				WithRetryDelay(0),
+				WithBudget(test.inputBudget),
+			)
+			require.Equal(t, test.wantAttempts, attempts)
+			require.Equal(t, test.wantErr, err != nil)
+			require.Equal(t, test.wantBudgetErr, errors.Is(err, ErrBudgetExhausted))
+		})
+	}
+}
+
+func TestDoBudgetMaxElapsedTime(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	budget := NewBudget(0, 1, WithBudgetTTL(10*time.Second), WithBudgetClock(fake))
+	require.Equal(t, 10, budget.Balance())
+
+	attempts := 0
+	err := Do(
+		func() error {
+			attempts++
+			fake.Advance(2 * time.Second)
+			return fmt.Errorf("")
+		},
+		WithRetryCount(3),
+		WithRetryDelay(0),
+		WithMaxElapsedTime(time.Second),
+		WithClock(fake),
+		WithBudget(budget),
+	)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrBudgetExhausted))
+	require.Equal(t, 1, attempts)
+	// повтора не было, значит и токен не списан
+	require.Equal(t, 10, budget.Balance())
+}
@@ -0,0 +1,109 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAttemptTimeout ошибка попытки, которая не уложилась в WithAttemptTimeout.
+// Позволяет в WithFailCallback отличить таймаут попытки от завершения родительского контекста
+var ErrAttemptTimeout = errors.New("retry: attempt timed out")
+
+// attemptTimeLayout формат времени попытки в тексте ошибки
+const attemptTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// AttemptError ошибка отдельной попытки
+type AttemptError struct {
+	// Attempt номер попытки (начиная с 1)
+	Attempt uint
+	// Time время, когда попытка завершилась ошибкой
+	Time time.Time
+	// Err ошибка попытки
+	Err error
+}
+
+func (e AttemptError) Error() string {
+	return fmt.Sprintf("attempt %d at %s: %v", e.Attempt, e.Time.Format(attemptTimeLayout), e.Err)
+}
+
+func (e AttemptError) Unwrap() error { return e.Err }
+
+// Error ошибка, которую возвращает Do когда попытки закончились.
+// Хранит историю ошибок всех попыток, errors.Is и errors.As проверяют каждую из них
+type Error struct {
+	Attempts []AttemptError
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "retry: %d attempts failed", len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		b.WriteString("\n\t")
+		b.WriteString(attempt.Error())
+	}
+	return b.String()
+}
+
+// Unwrap возвращает ошибки всех попыток
+func (e *Error) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt)
+	}
+	return errs
+}
+
+// Last возвращает ошибку последней попытки
+func (e *Error) Last() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// RetryAfterError ошибка, которая подсказывает сколько ждать перед следующей попыткой.
+// Если ошибка попытки (в том числе обернутая) реализует этот интерфейс, Do использует
+// подсказку вместо настроенной задержки (с учетом WithMaxRetryDelay)
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// retryAfter достает подсказку о задержке из ошибки: RetryAfterError
+// или RetryInfo в деталях gRPC статуса
+func retryAfter(err error) (time.Duration, bool) {
+	var hinted RetryAfterError
+	if errors.As(err, &hinted) {
+		return hinted.RetryAfter(), true
+	}
+	return grpcRetryAfter(err)
+}
+
+// permanentError ошибка, после которой повторять попытки бессмысленно
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как постоянную: получив ее, Do сразу прекращает
+// попытки и возвращает исходную (развернутую) ошибку
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// asPermanent проверяет является ли ошибка постоянной и возвращает исходную ошибку
+func asPermanent(err error) (error, bool) {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return permanent.err, true
+	}
+	return err, false
+}
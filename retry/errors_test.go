@@ -0,0 +1,47 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// codeError ошибка с кодом, для проверки errors.As
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string { return fmt.Sprintf("code %d", e.code) }
+
+func TestError(t *testing.T) {
+	at := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+	errFirst := errors.New("first")
+	errSecond := &codeError{code: 503}
+
+	err := error(&Error{Attempts: []AttemptError{
+		{Attempt: 1, Time: at, Err: errFirst},
+		{Attempt: 2, Time: at.Add(1500 * time.Millisecond), Err: fmt.Errorf("wrap: %w", errSecond)},
+	}})
+
+	require.ErrorIs(t, err, errFirst)
+
+	var code *codeError
+	require.ErrorAs(t, err, &code)
+	require.Equal(t, 503, code.code)
+
+	require.Equal(t,
+		"retry: 2 attempts failed\n"+
+			"\tattempt 1 at 2022-01-01T10:30:00.000Z: first\n"+
+			"\tattempt 2 at 2022-01-01T10:30:01.500Z: wrap: code 503",
+		err.Error(),
+	)
+}
+
+func TestErrorEmpty(t *testing.T) {
+	err := &Error{}
+	require.Nil(t, err.Last())
+	require.False(t, errors.Is(err, errors.New("")))
+}
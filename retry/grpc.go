@@ -0,0 +1,74 @@
+package retry
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCRetryableCodes коды ответа gRPC, при которых запрос имеет смысл повторить
+var DefaultGRPCRetryableCodes = []codes.Code{
+	codes.Unavailable,
+	codes.ResourceExhausted,
+	codes.Aborted,
+}
+
+// GRPCRetryIf классификатор ошибок для WithRetryIf, повторяет попытку
+// только если ошибка имеет один из DefaultGRPCRetryableCodes
+func GRPCRetryIf(err error) bool {
+	return RetryIfGRPCCodes(DefaultGRPCRetryableCodes...)(err)
+}
+
+// RetryIfGRPCCodes конструктор классификатора ошибок для WithRetryIf,
+// повторяет попытку только если ошибка имеет один из переданных кодов gRPC
+func RetryIfGRPCCodes(retryable ...codes.Code) func(error) bool {
+	return func(err error) bool {
+		st, ok := grpcStatus(err)
+		if !ok {
+			return false
+		}
+		for _, code := range retryable {
+			if st.Code() == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// grpcRetryAfter достает подсказку о задержке из деталей gRPC статуса (RetryInfo)
+func grpcRetryAfter(err error) (time.Duration, bool) {
+	st, ok := grpcStatus(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.RetryInfo)
+		if !ok || info.GetRetryDelay() == nil {
+			continue
+		}
+		return info.GetRetryDelay().AsDuration(), true
+	}
+	return 0, false
+}
+
+// grpcStatus достает gRPC статус из ошибки (в том числе обернутой)
+func grpcStatus(err error) (*status.Status, bool) {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return nil, false
+	}
+	return grpcErr.GRPCStatus(), true
+}
+
+// GRPCStatus возвращает gRPC статус последней попытки, что бы status.Code и
+// status.FromError работали с историей ошибок так же как с ошибкой одной попытки
+func (e *Error) GRPCStatus() *status.Status {
+	if st, ok := grpcStatus(e.Last()); ok {
+		return st
+	}
+	return status.New(codes.Unknown, e.Error())
+}
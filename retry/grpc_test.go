@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCRetryIf(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputErr  error
+		wantRetry bool
+	}{
+		{
+			name:      "unavailable",
+			inputErr:  status.Error(codes.Unavailable, "unavailable"),
+			wantRetry: true,
+		},
+		{
+			name:      "resource_exhausted",
+			inputErr:  status.Error(codes.ResourceExhausted, "exhausted"),
+			wantRetry: true,
+		},
+		{
+			name:      "wrapped_unavailable",
+			inputErr:  fmt.Errorf("call: %w", status.Error(codes.Unavailable, "unavailable")),
+			wantRetry: true,
+		},
+		{
+			name:      "invalid_argument",
+			inputErr:  status.Error(codes.InvalidArgument, "invalid"),
+			wantRetry: false,
+		},
+		{
+			name:      "not_grpc",
+			inputErr:  fmt.Errorf("plain"),
+			wantRetry: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.wantRetry, GRPCRetryIf(test.inputErr))
+		})
+	}
+}
+
+func TestErrorGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputErr *Error
+		wantCode codes.Code
+	}{
+		{
+			name: "last_grpc_error",
+			inputErr: &Error{Attempts: []AttemptError{
+				{Attempt: 1, Err: fmt.Errorf("plain")},
+				{Attempt: 2, Err: status.Error(codes.Unavailable, "unavailable")},
+			}},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name: "last_plain_error",
+			inputErr: &Error{Attempts: []AttemptError{
+				{Attempt: 1, Err: status.Error(codes.Unavailable, "unavailable")},
+				{Attempt: 2, Err: fmt.Errorf("plain")},
+			}},
+			wantCode: codes.Unknown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.wantCode, status.Code(test.inputErr))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// Hedge сделать hedged запрос: если попытка не ответила за WithHedgeDelay,
+// параллельно запускается еще одна, одновременно не больше WithMaxParallelAttempts.
+// Возвращается результат первой успешной попытки, остальные отменяются через контекст.
+// Упавшая попытка сразу заменяется новой, всего запускается не больше
+// WithRetryCount+1 попыток, как и в Do.
+//
+// Настройки WithRetryIf, WithAttemptTimeout, WithBudget, WithClock и callback-и
+// применяются так же как в Do (в WithSuccessCallback и WithGiveUpCallback передается
+// количество запущенных попыток). Настройки задержек между повторами
+// и WithBackoffCallback не используются.
+func Hedge[T any](
+	ctx context.Context,
+	hedgedFunc func(context.Context) (T, error),
+	opts ...Option,
+) (T, error) {
+	return HedgeWithPolicy(ctx, NewPolicy(opts...), hedgedFunc)
+}
+
+// HedgeWithPolicy сделать hedged запрос по политике, аналог Hedge
+func HedgeWithPolicy[T any](
+	ctx context.Context,
+	p Policy,
+	hedgedFunc func(context.Context) (T, error),
+) (T, error) {
+	hedgeOptions := &p.opts
+	maxParallel := hedgeOptions.maxParallelAttempts
+	if maxParallel == 0 {
+		maxParallel = 1
+	}
+	maxAttempts := hedgeOptions.retryCount + 1
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		attempt  uint
+		value    T
+		timedOut bool
+		err      error
+	}
+
+	var (
+		zero     T
+		launched uint
+		finished uint
+		history  = &Error{}
+		// budgetExhausted попытку не запустили только из-за пустого бюджета
+		budgetExhausted bool
+		// буфер на все попытки в полете, что бы опоздавшие горутины не зависали после выхода
+		results = make(chan result, maxParallel)
+	)
+	giveUp := func(err error) (T, error) {
+		hedgeOptions.giveUpCallback(launched, err)
+		return zero, err
+	}
+	launch := func() {
+		launched++
+		attempt := launched
+		hedgeOptions.beforeAttemptCallback(attempt)
+		go func() {
+			value, timedOut, err := doAttempt(ctx, hedgeOptions.attemptTimeout, hedgedFunc)
+			results <- result{attempt: attempt, value: value, timedOut: timedOut, err: err}
+		}()
+	}
+	// hasSlot можно ли запустить еще одну попытку по лимитам количества попыток
+	hasSlot := func() bool {
+		return launched < maxAttempts && launched-finished < maxParallel
+	}
+	canLaunch := func() bool {
+		if !hasSlot() {
+			return false
+		}
+		budgetExhausted = hedgeOptions.budget != nil && !hedgeOptions.budget.TryWithdraw()
+		return !budgetExhausted
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return giveUp(ctxErr)
+	}
+	launch()
+	for {
+		var (
+			timer  clock.Timer
+			hedgeC <-chan time.Time
+		)
+		if hasSlot() {
+			timer = hedgeOptions.clock.NewTimer(hedgeOptions.hedgeDelay)
+			hedgeC = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			return giveUp(fmt.Errorf("%w: %w", ctx.Err(), history))
+		case <-hedgeC:
+			if canLaunch() {
+				launch()
+			}
+		case res := <-results:
+			stopTimer(timer)
+			finished++
+			if res.err == nil {
+				if hedgeOptions.budget != nil {
+					hedgeOptions.budget.Deposit()
+				}
+				hedgeOptions.successCallback(launched)
+				return res.value, nil
+			}
+
+			history.Attempts = append(history.Attempts, AttemptError{
+				Attempt: res.attempt,
+				Time:    hedgeOptions.clock.Now(),
+				Err:     res.err,
+			})
+			hedgeOptions.retryFailCallback(res.attempt, res.err)
+			if err, ok := asPermanent(res.err); ok {
+				return giveUp(err)
+			}
+			if !res.timedOut && !hedgeOptions.retryIf(res.err) {
+				return giveUp(res.err)
+			}
+			if canLaunch() {
+				launch()
+			}
+			if finished == launched {
+				if budgetExhausted {
+					return giveUp(fmt.Errorf("%w: %w", ErrBudgetExhausted, history))
+				}
+				return giveUp(history)
+			}
+		}
+	}
+}
+
+// stopTimer останавливает таймер, если он был запущен
+func stopTimer(timer clock.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
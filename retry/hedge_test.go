@@ -0,0 +1,180 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+func TestHedgeSlowFirstAttempt(t *testing.T) {
+	fake := clocktest.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	firstCanceled := make(chan struct{})
+	done := make(chan struct{})
+	var (
+		value string
+		err   error
+	)
+	go func() {
+		defer close(done)
+		value, err = Hedge(
+			context.Background(),
+			func(ctx context.Context) (string, error) {
+				mu.Lock()
+				attempts++
+				attempt := attempts
+				mu.Unlock()
+				if attempt == 1 {
+					<-ctx.Done()
+					close(firstCanceled)
+					return "", ctx.Err()
+				}
+				return "second", nil
+			},
+			WithHedgeDelay(time.Second),
+			WithMaxParallelAttempts(3),
+			WithClock(fake),
+		)
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	<-done
+	<-firstCanceled
+
+	require.NoError(t, err)
+	require.Equal(t, "second", value)
+	require.Equal(t, 2, attempts)
+	require.Zero(t, fake.Timers())
+}
+
+func TestHedgeFailures(t *testing.T) {
+	errAttempt := errors.New("attempt")
+	errValidation := errors.New("validation")
+
+	tests := []struct {
+		name             string
+		inputMaxParallel uint
+		inputRetryCount  uint
+		inputErr         error
+		inputBudget      *Budget
+		wantAttempts     int
+		wantErr          error
+		wantHistory      bool
+		wantBudgetErr    bool
+	}{
+		{
+			name:             "all_failed",
+			inputMaxParallel: 3,
+			inputRetryCount:  2,
+			inputErr:         errAttempt,
+			wantAttempts:     3,
+			wantErr:          errAttempt,
+			wantHistory:      true,
+		},
+		{
+			name:             "permanent",
+			inputMaxParallel: 3,
+			inputRetryCount:  2,
+			inputErr:         Permanent(errValidation),
+			wantAttempts:     1,
+			wantErr:          errValidation,
+			wantHistory:      false,
+		},
+		{
+			name:             "replace_failed_one_by_one",
+			inputMaxParallel: 1,
+			inputRetryCount:  2,
+			inputErr:         errAttempt,
+			wantAttempts:     3,
+			wantErr:          errAttempt,
+			wantHistory:      true,
+		},
+		{
+			name:             "zero_parallel",
+			inputMaxParallel: 0,
+			inputRetryCount:  0,
+			inputErr:         errAttempt,
+			wantAttempts:     1,
+			wantErr:          errAttempt,
+			wantHistory:      true,
+		},
+		{
+			name:             "budget_exhausted",
+			inputMaxParallel: 1,
+			inputRetryCount:  2,
+			inputErr:         errAttempt,
+			inputBudget:      NewBudget(0, 0),
+			wantAttempts:     1,
+			wantErr:          errAttempt,
+			wantHistory:      true,
+			wantBudgetErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mu        sync.Mutex
+				attempts  int
+				callbacks []uint
+			)
+			_, err := Hedge(
+				context.Background(),
+				func(context.Context) (int, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					attempts++
+					return 0, test.inputErr
+				},
+				WithHedgeDelay(time.Hour),
+				WithMaxParallelAttempts(test.inputMaxParallel),
+				WithRetryCount(test.inputRetryCount),
+				WithBudget(test.inputBudget),
+				WithFailCallback(func(attempt uint, _ error) {
+					callbacks = append(callbacks, attempt)
+				}),
+			)
+
+			require.ErrorIs(t, err, test.wantErr)
+			require.Equal(t, test.wantBudgetErr, errors.Is(err, ErrBudgetExhausted))
+			require.Equal(t, test.wantAttempts, attempts)
+			require.Len(t, callbacks, test.wantAttempts)
+
+			var history *Error
+			require.Equal(t, test.wantHistory, errors.As(err, &history))
+			if test.wantHistory {
+				require.Len(t, history.Attempts, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestHedgeContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := Hedge(
+		ctx,
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 0, fmt.Errorf("attempt: %w", ctx.Err())
+		},
+		WithHedgeDelay(time.Hour),
+	)
+	require.ErrorIs(t, err, context.Canceled)
+}
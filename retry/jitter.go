@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RandSource источник случайных чисел для стратегий с джиттером.
+// *rand.Rand удовлетворяет этому интерфейсу, но не является потоко-безопасным
+type RandSource interface {
+	// Int63n возвращает случайное число в полуинтервале [0, n)
+	Int63n(n int64) int64
+}
+
+// globalRandSource потоко-безопасный источник на основе глобального генератора math/rand
+type globalRandSource struct{}
+
+func (globalRandSource) Int63n(n int64) int64 { return rand.Int63n(n) }
+
+// DefaultJitter джиттер по умолчанию (время задержки не изменяется)
+func DefaultJitter(dur time.Duration) time.Duration { return dur }
+
+// FullJitter конструктор джиттера, который выбирает задержку случайно из [0, dur].
+// Если rnd равен nil, используется глобальный генератор math/rand
+func FullJitter(rnd RandSource) func(time.Duration) time.Duration {
+	rnd = randSourceOrDefault(rnd)
+	return func(dur time.Duration) time.Duration {
+		return randDuration(rnd, 0, dur)
+	}
+}
+
+// EqualJitter конструктор джиттера, который выбирает задержку случайно из [dur/2, dur].
+// Если rnd равен nil, используется глобальный генератор math/rand
+func EqualJitter(rnd RandSource) func(time.Duration) time.Duration {
+	rnd = randSourceOrDefault(rnd)
+	return func(dur time.Duration) time.Duration {
+		return randDuration(rnd, dur/2, dur)
+	}
+}
+
+// DecorrelatedJitterMutateRetryDelay конструктор функции изменяющей время задержки
+// по схеме decorrelated jitter: следующая задержка выбирается случайно из [base, 3*dur].
+// Ограничение сверху задается через WithMaxRetryDelay.
+// Если rnd равен nil, используется глобальный генератор math/rand
+func DecorrelatedJitterMutateRetryDelay(
+	base time.Duration,
+	rnd RandSource,
+) func(time.Duration) time.Duration {
+	rnd = randSourceOrDefault(rnd)
+	return func(dur time.Duration) time.Duration {
+		return randDuration(rnd, base, 3*dur)
+	}
+}
+
+// randDuration возвращает случайную длительность из [from, to]
+func randDuration(rnd RandSource, from, to time.Duration) time.Duration {
+	if to <= from {
+		return from
+	}
+	return from + time.Duration(rnd.Int63n(int64(to-from)+1))
+}
+
+func randSourceOrDefault(rnd RandSource) RandSource {
+	if rnd == nil {
+		return globalRandSource{}
+	}
+	return rnd
+}
@@ -0,0 +1,108 @@
+package retry
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// boundRandSource источник, который всегда возвращает минимальное или максимальное значение
+type boundRandSource struct {
+	max bool
+}
+
+func (s boundRandSource) Int63n(n int64) int64 {
+	if s.max {
+		return n - 1
+	}
+	return 0
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputDelay time.Duration
+		inputFunc  func(time.Duration) time.Duration
+		wantDelay  time.Duration
+	}{
+		{
+			name:       "full_jitter_min",
+			inputDelay: time.Second,
+			inputFunc:  FullJitter(boundRandSource{max: false}),
+			wantDelay:  0,
+		},
+		{
+			name:       "full_jitter_max",
+			inputDelay: time.Second,
+			inputFunc:  FullJitter(boundRandSource{max: true}),
+			wantDelay:  time.Second,
+		},
+		{
+			name:       "equal_jitter_min",
+			inputDelay: time.Second,
+			inputFunc:  EqualJitter(boundRandSource{max: false}),
+			wantDelay:  500 * time.Millisecond,
+		},
+		{
+			name:       "equal_jitter_max",
+			inputDelay: time.Second,
+			inputFunc:  EqualJitter(boundRandSource{max: true}),
+			wantDelay:  time.Second,
+		},
+		{
+			name:       "decorrelated_jitter_min",
+			inputDelay: time.Second,
+			inputFunc:  DecorrelatedJitterMutateRetryDelay(100*time.Millisecond, boundRandSource{max: false}),
+			wantDelay:  100 * time.Millisecond,
+		},
+		{
+			name:       "decorrelated_jitter_max",
+			inputDelay: time.Second,
+			inputFunc:  DecorrelatedJitterMutateRetryDelay(100*time.Millisecond, boundRandSource{max: true}),
+			wantDelay:  3 * time.Second,
+		},
+		{
+			name:       "zero_delay",
+			inputDelay: 0,
+			inputFunc:  FullJitter(boundRandSource{max: true}),
+			wantDelay:  0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.wantDelay, test.inputFunc(test.inputDelay))
+		})
+	}
+}
+
+func TestJitterDeterministic(t *testing.T) {
+	first := FullJitter(rand.New(rand.NewSource(42)))
+	second := FullJitter(rand.New(rand.NewSource(42)))
+	for i := 0; i < 10; i++ {
+		delay := first(time.Second)
+		require.Equal(t, delay, second(time.Second))
+		require.GreaterOrEqual(t, delay, time.Duration(0))
+		require.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestDoMaxRetryDelay(t *testing.T) {
+	start := time.Now()
+	err := Do(
+		func() error { return fmt.Errorf("") },
+		WithRetryCount(4),
+		WithRetryDelay(time.Millisecond),
+		WithRetryDelayMutation(ExpMutateRetryDelay),
+		WithMaxRetryDelay(2*time.Millisecond),
+	)
+	execDur := time.Since(start)
+
+	// 1 + 2 + 2 + 2 вместо 1 + 2 + 4 + 8
+	require.Error(t, err)
+	require.Less(t, execDur, 12*time.Millisecond)
+	require.Greater(t, execDur, 7*time.Millisecond)
+}
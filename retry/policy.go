@@ -0,0 +1,167 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
+
+// Policy неизменяемая политика повторов, собранная из Option.
+// Ее можно создать один раз, хранить в структуре сервиса и использовать
+// конкурентно из разных горутин (при условии что переданные в Option функции
+// потоко-безопасны).
+type Policy struct {
+	opts options
+}
+
+// NewPolicy конструктор политики повторов
+func NewPolicy(opts ...Option) Policy {
+	policyOptions := newDefaultOptions()
+	for _, opt := range opts {
+		opt(&policyOptions)
+	}
+	return Policy{opts: policyOptions}
+}
+
+// With возвращает копию политики с дополнительно примененными opts,
+// исходная политика не изменяется
+func (p Policy) With(opts ...Option) Policy {
+	policyOptions := p.opts
+	for _, opt := range opts {
+		opt(&policyOptions)
+	}
+	return Policy{opts: policyOptions}
+}
+
+// Do сделать в несколько попыток по политике, аналог DoContext
+func (p Policy) Do(ctx context.Context, retryableFunc func(context.Context) error) error {
+	_, err := DoValueWithPolicy(
+		ctx,
+		p,
+		func(ctx context.Context) (struct{}, error) { return struct{}{}, retryableFunc(ctx) },
+	)
+	return err
+}
+
+// DoValueWithPolicy сделать в несколько попыток по политике и вернуть результат
+// первой успешной, аналог DoValue (методы в Go не могут иметь параметров типа,
+// поэтому это функция, а не метод Policy)
+func DoValueWithPolicy[T any](
+	ctx context.Context,
+	p Policy,
+	retryableFunc func(context.Context) (T, error),
+) (T, error) {
+	retryOptions := &p.opts
+
+	var (
+		attempt    uint
+		zero       T
+		retryDelay = retryOptions.retryDelay
+		start      = retryOptions.clock.Now()
+		history    = &Error{}
+	)
+	giveUp := func(err error) (T, error) {
+		retryOptions.giveUpCallback(attempt, err)
+		return zero, err
+	}
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if len(history.Attempts) > 0 {
+				return giveUp(fmt.Errorf("%w: %w", ctxErr, history))
+			}
+			return giveUp(ctxErr)
+		}
+		attempt++
+		retryOptions.beforeAttemptCallback(attempt)
+		attemptStart := retryOptions.clock.Now()
+		value, timedOut, err := doAttempt(ctx, retryOptions.attemptTimeout, retryableFunc)
+		if err == nil {
+			if retryOptions.budget != nil {
+				retryOptions.budget.Deposit()
+			}
+			retryOptions.successCallback(attempt)
+			return value, nil
+		}
+
+		history.Attempts = append(history.Attempts, AttemptError{
+			Attempt: attempt,
+			Time:    retryOptions.clock.Now(),
+			Err:     err,
+		})
+		retryOptions.retryFailCallback(attempt, err)
+		if err, ok := asPermanent(err); ok {
+			return giveUp(err)
+		}
+		if !timedOut && !retryOptions.retryIf(err) {
+			return giveUp(err)
+		}
+		if attempt > retryOptions.retryCount {
+			return giveUp(history)
+		}
+		delay := retryOptions.jitter(retryDelay)
+		if hint, ok := retryAfter(err); ok {
+			delay = hint
+		}
+		delay = retryOptions.capDelay(delay)
+		if retryOptions.maxElapsedTime > 0 {
+			// следующая попытка должна успеть завершиться до истечения бюджета времени,
+			// считаем что она займет столько же сколько предыдущая
+			now := retryOptions.clock.Now()
+			remaining := retryOptions.maxElapsedTime - now.Sub(start) - now.Sub(attemptStart)
+			if remaining <= 0 {
+				return giveUp(history)
+			}
+			if delay > remaining {
+				delay = remaining
+			}
+		}
+		// токен бюджета тратится только когда повтор точно будет
+		if retryOptions.budget != nil && !retryOptions.budget.TryWithdraw() {
+			return giveUp(fmt.Errorf("%w: %w", ErrBudgetExhausted, history))
+		}
+		retryOptions.backoffCallback(attempt, err, delay)
+		if ctxErr := sleep(ctx, retryOptions.clock, delay); ctxErr != nil {
+			return giveUp(fmt.Errorf("%w: %w", ctxErr, history))
+		}
+		retryDelay = retryOptions.capDelay(retryOptions.mutateRetryDelay(retryDelay))
+	}
+}
+
+// doAttempt выполняет одну попытку с учетом WithAttemptTimeout.
+// Если попытка не уложилась в свой таймаут (а родительский контекст еще жив),
+// ошибка оборачивается в ErrAttemptTimeout и timedOut равен true
+func doAttempt[T any](
+	ctx context.Context,
+	timeout time.Duration,
+	retryableFunc func(context.Context) (T, error),
+) (value T, timedOut bool, err error) {
+	if timeout <= 0 {
+		value, err = retryableFunc(ctx)
+		return value, false, err
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	value, err = retryableFunc(attemptCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return value, true, fmt.Errorf("%w: %w", ErrAttemptTimeout, err)
+	}
+	return value, false, err
+}
+
+// sleep ждет delay или завершения контекста, в последнем случае возвращает ctx.Err()
+func sleep(ctx context.Context, clk clock.Clock, delay time.Duration) error {
+	timer := clk.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
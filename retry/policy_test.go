@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
+)
+
+func TestPolicyReuse(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clocktest.NewFake(start)
+	policy := NewPolicy(
+		WithRetryCount(2),
+		WithRetryDelay(time.Minute),
+		WithRetryDelayMutation(ExpMutateRetryDelay),
+		WithClock(fake),
+	)
+
+	// задержка не должна накапливаться между вызовами одной политики
+	for i := 0; i < 2; i++ {
+		callStart := fake.Now()
+		var attemptOffsets []time.Duration
+		done := make(chan error)
+		go func() {
+			done <- policy.Do(context.Background(), func(context.Context) error {
+				attemptOffsets = append(attemptOffsets, fake.Now().Sub(callStart))
+				return fmt.Errorf("")
+			})
+		}()
+
+		require.Error(t, advanceUntilDone(fake, time.Minute, done))
+		require.Equal(t, []time.Duration{0, time.Minute, 3 * time.Minute}, attemptOffsets)
+	}
+}
+
+func TestPolicyWith(t *testing.T) {
+	base := NewPolicy(WithRetryCount(1), WithRetryDelay(0))
+	derived := base.With(WithRetryCount(3))
+
+	countAttempts := func(p Policy) int {
+		attempts := 0
+		_ = p.Do(context.Background(), func(context.Context) error {
+			attempts++
+			return fmt.Errorf("")
+		})
+		return attempts
+	}
+
+	require.Equal(t, 2, countAttempts(base))
+	require.Equal(t, 4, countAttempts(derived))
+}
+
+func TestPolicyConcurrent(t *testing.T) {
+	policy := NewPolicy(WithRetryCount(3), WithRetryDelay(time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			attempts := 0
+			value, err := DoValueWithPolicy(context.Background(), policy, func(context.Context) (int, error) {
+				attempts++
+				if attempts < 3 {
+					return 0, fmt.Errorf("")
+				}
+				return i, nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, i, value)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,29 +1,49 @@
 package retry
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/St0rmPetrel/handydandylib/clock"
+)
 
 // Do сделать в несколько попыток
 func Do(retryableFunc func() error, opts ...Option) error {
-	retryOptions := newDefaultOptions()
-	for _, opt := range opts {
-		opt(retryOptions)
-	}
-
-	var attempt uint
-	for {
-		attempt++
-		err := retryableFunc()
-		if err != nil {
-			retryOptions.retryFailCallback(attempt, err)
-			if attempt > retryOptions.retryCount {
-				return err
-			}
-			time.Sleep(retryOptions.retryDelay)
-			retryOptions.retryDelay = retryOptions.mutateRetryDelay(retryOptions.retryDelay)
-			continue
-		}
-		return nil
-	}
+	return DoContext(
+		context.Background(),
+		func(context.Context) error { return retryableFunc() },
+		opts...,
+	)
+}
+
+// DoContext сделать в несколько попыток с учетом контекста.
+// Контекст передается в retryableFunc, а ожидание перед очередной попыткой
+// прерывается как только контекст завершен. В этом случае возвращаемая ошибка
+// оборачивает и ctx.Err(), и историю ошибок попыток.
+//
+// Когда попытки заканчиваются, возвращается *Error с историей ошибок всех попыток.
+// Ошибка, помеченная Permanent или отвергнутая WithRetryIf, возвращается как есть.
+func DoContext(
+	ctx context.Context,
+	retryableFunc func(context.Context) error,
+	opts ...Option,
+) error {
+	_, err := DoValue(
+		ctx,
+		func(ctx context.Context) (struct{}, error) { return struct{}{}, retryableFunc(ctx) },
+		opts...,
+	)
+	return err
+}
+
+// DoValue сделать в несколько попыток и вернуть результат первой успешной.
+// Поведение аналогично DoContext, при неудаче возвращается нулевое значение T.
+func DoValue[T any](
+	ctx context.Context,
+	retryableFunc func(context.Context) (T, error),
+	opts ...Option,
+) (T, error) {
+	return DoValueWithPolicy(ctx, NewPolicy(opts...), retryableFunc)
 }
 
 // Option функция для настройки повеления Do
@@ -36,6 +56,67 @@ func WithRetryDelayMutation(mutateDelay func(time.Duration) time.Duration) Optio
 	}
 }
 
+// WithJitter настраивает случайное изменение задержки перед очередной попыткой
+// (например FullJitter или EqualJitter). В отличии от WithRetryDelayMutation
+// результат джиттера не влияет на последующие задержки
+func WithJitter(jitter func(time.Duration) time.Duration) Option {
+	return func(opts *options) {
+		opts.jitter = jitter
+	}
+}
+
+// WithMaxRetryDelay настраивает максимальную длительность паузы перед новой попыткой
+// (0 - без ограничения)
+func WithMaxRetryDelay(maxDelay time.Duration) Option {
+	return func(opts *options) {
+		opts.maxRetryDelay = maxDelay
+	}
+}
+
+// WithMaxElapsedTime настраивает общий бюджет времени на все попытки (0 - без ограничения).
+// Do не начинает попытку, которая не успеет завершиться до истечения бюджета
+// (длительность попытки оценивается по предыдущей), и сокращает последнюю паузу
+// так, что бы не проспать окончание бюджета
+func WithMaxElapsedTime(maxElapsed time.Duration) Option {
+	return func(opts *options) {
+		opts.maxElapsedTime = maxElapsed
+	}
+}
+
+// WithAttemptTimeout настраивает таймаут отдельной попытки (0 - без ограничения).
+// Каждая попытка получает производный контекст с этим таймаутом, а ошибка попытки,
+// не уложившейся в таймаут, оборачивается в ErrAttemptTimeout и всегда считается
+// подлежащей повтору
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.attemptTimeout = timeout
+	}
+}
+
+// WithBudget настраивает общий бюджет повторов. Если бюджет исчерпан,
+// Do сразу завершается с ошибкой, оборачивающей ErrBudgetExhausted
+func WithBudget(budget *Budget) Option {
+	return func(opts *options) {
+		opts.budget = budget
+	}
+}
+
+// WithHedgeDelay настраивает через сколько после запуска последней попытки
+// Hedge запускает следующую параллельную попытку
+func WithHedgeDelay(delay time.Duration) Option {
+	return func(opts *options) {
+		opts.hedgeDelay = delay
+	}
+}
+
+// WithMaxParallelAttempts настраивает максимальное количество попыток,
+// которые Hedge выполняет одновременно (всего попыток не больше WithRetryCount+1)
+func WithMaxParallelAttempts(count uint) Option {
+	return func(opts *options) {
+		opts.maxParallelAttempts = count
+	}
+}
+
 // WithFailCallback настраивает функцию которая исполняется после очередной
 // неудачной попытки
 func WithFailCallback(callback func(uint, error)) Option {
@@ -44,6 +125,71 @@ func WithFailCallback(callback func(uint, error)) Option {
 	}
 }
 
+// WithBeforeAttemptCallback настраивает функцию которая исполняется перед каждой попыткой.
+// В отличии от WithFailCallback, этот и остальные callback-и жизненного цикла
+// (WithBackoffCallback, WithSuccessCallback, WithGiveUpCallback) добавляются
+// к заданным ранее и вызываются после них, поэтому не переопределяются через Policy.With
+func WithBeforeAttemptCallback(callback func(attempt uint)) Option {
+	return func(opts *options) {
+		prev := opts.beforeAttemptCallback
+		opts.beforeAttemptCallback = func(attempt uint) {
+			prev(attempt)
+			callback(attempt)
+		}
+	}
+}
+
+// WithBackoffCallback настраивает функцию которая исполняется после неудачной попытки,
+// если будет следующая, и получает выбранную задержку перед ней
+func WithBackoffCallback(callback func(attempt uint, err error, delay time.Duration)) Option {
+	return func(opts *options) {
+		prev := opts.backoffCallback
+		opts.backoffCallback = func(attempt uint, err error, delay time.Duration) {
+			prev(attempt, err, delay)
+			callback(attempt, err, delay)
+		}
+	}
+}
+
+// WithSuccessCallback настраивает функцию которая исполняется после успешной попытки
+// и получает количество сделанных попыток
+func WithSuccessCallback(callback func(attempts uint)) Option {
+	return func(opts *options) {
+		prev := opts.successCallback
+		opts.successCallback = func(attempts uint) {
+			prev(attempts)
+			callback(attempts)
+		}
+	}
+}
+
+// WithGiveUpCallback настраивает функцию которая исполняется когда Do завершается
+// с ошибкой и получает количество сделанных попыток и возвращаемую ошибку
+func WithGiveUpCallback(callback func(attempts uint, err error)) Option {
+	return func(opts *options) {
+		prev := opts.giveUpCallback
+		opts.giveUpCallback = func(attempts uint, err error) {
+			prev(attempts, err)
+			callback(attempts, err)
+		}
+	}
+}
+
+// WithRetryIf настраивает классификатор ошибок: попытка повторяется только
+// если retryIf вернул true (по умолчанию повторяется при любой ошибке)
+func WithRetryIf(retryIf func(error) bool) Option {
+	return func(opts *options) {
+		opts.retryIf = retryIf
+	}
+}
+
+// WithClock настраивает источник времени (используется в тестах)
+func WithClock(clk clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = clk
+	}
+}
+
 // WithRetryCount настраивает количество попыток после которых Do перестает пытаться и завершается с ошибкой
 func WithRetryCount(count uint) Option {
 	return func(opts *options) {
@@ -63,11 +209,30 @@ const (
 	DefaultRetryCount uint = 3
 	// DefaultRetryDelay длительность задержки перед новой попыткой по умолчанию
 	DefaultRetryDelay time.Duration = time.Second
+	// DefaultHedgeDelay задержка перед запуском параллельной попытки в Hedge по умолчанию
+	DefaultHedgeDelay time.Duration = 100 * time.Millisecond
+	// DefaultMaxParallelAttempts максимальное количество параллельных попыток в Hedge по умолчанию
+	DefaultMaxParallelAttempts uint = 2
 )
 
 // DefaultRetryCallback функция вызываемая после безуспешной попытки по умолчанию
 func DefaultRetryCallback(_ uint, _ error) {}
 
+// DefaultBeforeAttemptCallback функция вызываемая перед попыткой по умолчанию
+func DefaultBeforeAttemptCallback(_ uint) {}
+
+// DefaultBackoffCallback функция вызываемая перед паузой по умолчанию
+func DefaultBackoffCallback(_ uint, _ error, _ time.Duration) {}
+
+// DefaultSuccessCallback функция вызываемая после успешной попытки по умолчанию
+func DefaultSuccessCallback(_ uint) {}
+
+// DefaultGiveUpCallback функция вызываемая после отказа от попыток по умолчанию
+func DefaultGiveUpCallback(_ uint, _ error) {}
+
+// DefaultRetryIf классификатор ошибок по умолчанию (повторять при любой ошибке)
+func DefaultRetryIf(_ error) bool { return true }
+
 // DefaultMutateRetryDelay функция изменяющая время задержки по умолчанию (время задержки перед попыткой не изменяется)
 func DefaultMutateRetryDelay(dur time.Duration) time.Duration { return dur }
 
@@ -81,16 +246,50 @@ func ExpMutateRetryDelay(dur time.Duration) time.Duration { return dur << 1 }
 type options struct {
 	retryCount        uint
 	retryDelay        time.Duration
+	maxRetryDelay     time.Duration
+	maxElapsedTime    time.Duration
+	attemptTimeout    time.Duration
 	mutateRetryDelay  func(time.Duration) time.Duration
+	jitter            func(time.Duration) time.Duration
 	retryFailCallback func(uint, error)
+	retryIf           func(error) bool
+	clock             clock.Clock
+	budget            *Budget
+
+	beforeAttemptCallback func(uint)
+	backoffCallback       func(uint, error, time.Duration)
+	successCallback       func(uint)
+	giveUpCallback        func(uint, error)
+
+	hedgeDelay          time.Duration
+	maxParallelAttempts uint
 }
 
 // newDefaultOptions конструктор настроек по умолчанию
-func newDefaultOptions() *options {
-	return &options{
+func newDefaultOptions() options {
+	return options{
 		retryCount:        DefaultRetryCount,
 		retryDelay:        DefaultRetryDelay,
 		retryFailCallback: DefaultRetryCallback,
 		mutateRetryDelay:  DefaultMutateRetryDelay,
+		jitter:            DefaultJitter,
+		retryIf:           DefaultRetryIf,
+		clock:             clock.New(),
+
+		beforeAttemptCallback: DefaultBeforeAttemptCallback,
+		backoffCallback:       DefaultBackoffCallback,
+		successCallback:       DefaultSuccessCallback,
+		giveUpCallback:        DefaultGiveUpCallback,
+
+		hedgeDelay:          DefaultHedgeDelay,
+		maxParallelAttempts: DefaultMaxParallelAttempts,
+	}
+}
+
+// capDelay ограничивает задержку сверху значением maxRetryDelay
+func (o *options) capDelay(delay time.Duration) time.Duration {
+	if o.maxRetryDelay > 0 && delay > o.maxRetryDelay {
+		return o.maxRetryDelay
 	}
+	return delay
 }
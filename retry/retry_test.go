@@ -1,11 +1,19 @@
 package retry
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/St0rmPetrel/handydandylib/clock/clocktest"
 )
 
 func TestDoSimpleNegative(t *testing.T) {
@@ -307,3 +315,530 @@ func TestDoMutateDelayNegative(t *testing.T) {
 		})
 	}
 }
+
+func TestDoContextCancel(t *testing.T) {
+	errAttempt := fmt.Errorf("attempt")
+
+	tests := []struct {
+		name            string
+		inputCtx        func() (context.Context, context.CancelFunc)
+		inputRetryDelay time.Duration
+		wantAttempts    int
+		wantErrs        []error
+		wantMaxExecTime time.Duration
+	}{
+		{
+			name: "canceled_before_first_attempt",
+			inputCtx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			inputRetryDelay: time.Second,
+			wantAttempts:    0,
+			wantErrs:        []error{context.Canceled},
+			wantMaxExecTime: 100 * time.Millisecond,
+		},
+		{
+			name: "deadline_while_sleeping",
+			inputCtx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			inputRetryDelay: 5 * time.Second,
+			wantAttempts:    1,
+			wantErrs:        []error{context.DeadlineExceeded, errAttempt},
+			wantMaxExecTime: time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := test.inputCtx()
+			defer cancel()
+
+			attempts := 0
+			start := time.Now()
+			err := DoContext(
+				ctx,
+				func(attemptCtx context.Context) error {
+					require.Equal(t, ctx, attemptCtx)
+					attempts++
+					return errAttempt
+				},
+				WithRetryCount(10),
+				WithRetryDelay(test.inputRetryDelay),
+			)
+			execDur := time.Since(start)
+
+			require.Equal(t, test.wantAttempts, attempts)
+			for _, wantErr := range test.wantErrs {
+				require.ErrorIs(t, err, wantErr)
+			}
+			require.Less(t, execDur, test.wantMaxExecTime)
+		})
+	}
+}
+
+func TestDoValue(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputRetryCount uint
+		inputFailures   int
+		wantValue       string
+		wantErr         bool
+		wantAttempts    int
+	}{
+		{
+			name:            "first_attempt",
+			inputRetryCount: 3,
+			inputFailures:   0,
+			wantValue:       "attempt 1",
+			wantAttempts:    1,
+		},
+		{
+			name:            "after_retries",
+			inputRetryCount: 3,
+			inputFailures:   2,
+			wantValue:       "attempt 3",
+			wantAttempts:    3,
+		},
+		{
+			name:            "give_up",
+			inputRetryCount: 1,
+			inputFailures:   5,
+			wantValue:       "",
+			wantErr:         true,
+			wantAttempts:    2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			value, err := DoValue(
+				context.Background(),
+				func(context.Context) (string, error) {
+					attempts++
+					if attempts <= test.inputFailures {
+						return "partial", fmt.Errorf("attempt %d", attempts)
+					}
+					return fmt.Sprintf("attempt %d", attempts), nil
+				},
+				WithRetryCount(test.inputRetryCount),
+				WithRetryDelay(time.Millisecond),
+			)
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.wantValue, value)
+			require.Equal(t, test.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestDoStopRetrying(t *testing.T) {
+	errValidation := fmt.Errorf("validation")
+	errTemporary := fmt.Errorf("temporary")
+
+	tests := []struct {
+		name               string
+		inputRetryIf       func(error) bool
+		inputRetryableFunc func() error
+		wantAttempts       int
+		wantErr            error
+		wantHistory        bool
+	}{
+		{
+			name:               "permanent",
+			inputRetryIf:       DefaultRetryIf,
+			inputRetryableFunc: func() error { return Permanent(errValidation) },
+			wantAttempts:       1,
+			wantErr:            errValidation,
+		},
+		{
+			name:               "wrapped_permanent",
+			inputRetryIf:       DefaultRetryIf,
+			inputRetryableFunc: func() error { return fmt.Errorf("wrap: %w", Permanent(errValidation)) },
+			wantAttempts:       1,
+			wantErr:            errValidation,
+		},
+		{
+			name:               "retry_if_false",
+			inputRetryIf:       func(err error) bool { return err == errTemporary },
+			inputRetryableFunc: func() error { return errValidation },
+			wantAttempts:       1,
+			wantErr:            errValidation,
+		},
+		{
+			name:               "retry_if_true",
+			inputRetryIf:       func(err error) bool { return err == errTemporary },
+			inputRetryableFunc: func() error { return errTemporary },
+			wantAttempts:       4,
+			wantErr:            errTemporary,
+			wantHistory:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := Do(
+				func() error {
+					attempts++
+					return test.inputRetryableFunc()
+				},
+				WithRetryCount(3),
+				WithRetryDelay(time.Millisecond),
+				WithRetryIf(test.inputRetryIf),
+			)
+			if test.wantHistory {
+				var history *Error
+				require.ErrorAs(t, err, &history)
+				require.Len(t, history.Attempts, test.wantAttempts)
+				require.Equal(t, test.wantErr, history.Last())
+			} else {
+				require.Equal(t, test.wantErr, err)
+			}
+			require.Equal(t, test.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestDoFakeClock(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clocktest.NewFake(start)
+
+	var attemptTimes []time.Time
+	done := make(chan error)
+	go func() {
+		done <- Do(
+			func() error {
+				attemptTimes = append(attemptTimes, fake.Now())
+				return fmt.Errorf("")
+			},
+			WithRetryCount(3),
+			WithRetryDelay(time.Hour),
+			WithRetryDelayMutation(ExpMutateRetryDelay),
+			WithClock(fake),
+		)
+	}()
+
+	for _, delay := range []time.Duration{time.Hour, 2 * time.Hour, 4 * time.Hour} {
+		fake.BlockUntil(1)
+		fake.Advance(delay)
+	}
+	require.Error(t, <-done)
+	require.Equal(t, []time.Time{
+		start,
+		start.Add(time.Hour),
+		start.Add(3 * time.Hour),
+		start.Add(7 * time.Hour),
+	}, attemptTimes)
+}
+
+// advanceUntilDone двигает часы шагом step, пока Do ждет таймер, и возвращает результат Do
+func advanceUntilDone(fake *clocktest.Fake, step time.Duration, done <-chan error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var err error
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		err = <-done
+		cancel()
+	}()
+
+	for fake.BlockUntilContext(ctx, 1) == nil {
+		fake.Advance(step)
+	}
+	<-finished
+	return err
+}
+
+func TestDoMaxElapsedTime(t *testing.T) {
+	tests := []struct {
+		name                string
+		inputMaxElapsedTime time.Duration
+		inputAttemptDur     time.Duration
+		inputRetryDelay     time.Duration
+		wantAttemptOffsets  []time.Duration
+	}{
+		{
+			name:                "fits_budget",
+			inputMaxElapsedTime: time.Hour,
+			inputAttemptDur:     time.Minute,
+			inputRetryDelay:     10 * time.Minute,
+			wantAttemptOffsets:  []time.Duration{0, 11 * time.Minute, 22 * time.Minute, 33 * time.Minute},
+		},
+		{
+			name:                "stop_before_attempt_out_of_budget",
+			inputMaxElapsedTime: 30 * time.Minute,
+			inputAttemptDur:     10 * time.Minute,
+			inputRetryDelay:     time.Minute,
+			// третья попытка началась бы в 22m и не успела бы завершиться к 30m
+			wantAttemptOffsets: []time.Duration{0, 11 * time.Minute},
+		},
+		{
+			name:                "clamp_last_sleep",
+			inputMaxElapsedTime: 30 * time.Minute,
+			inputAttemptDur:     time.Minute,
+			inputRetryDelay:     20 * time.Minute,
+			// вторая пауза сокращается до 30m - 22m - 1m = 7m
+			wantAttemptOffsets: []time.Duration{0, 21 * time.Minute, 29 * time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			fake := clocktest.NewFake(start)
+
+			var attemptOffsets []time.Duration
+			done := make(chan error)
+			go func() {
+				done <- Do(
+					func() error {
+						attemptOffsets = append(attemptOffsets, fake.Now().Sub(start))
+						fake.Advance(test.inputAttemptDur)
+						return fmt.Errorf("")
+					},
+					WithRetryCount(3),
+					WithRetryDelay(test.inputRetryDelay),
+					WithMaxElapsedTime(test.inputMaxElapsedTime),
+					WithClock(fake),
+				)
+			}()
+
+			err := advanceUntilDone(fake, time.Minute, done)
+			require.Error(t, err)
+			require.Equal(t, test.wantAttemptOffsets, attemptOffsets)
+		})
+	}
+}
+
+func TestDoErrorHistory(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clocktest.NewFake(start)
+	errs := []error{fmt.Errorf("first"), fmt.Errorf("second"), fmt.Errorf("third")}
+
+	attempt := 0
+	err := Do(
+		func() error {
+			defer func() { attempt++ }()
+			fake.Advance(time.Second)
+			return errs[attempt]
+		},
+		WithRetryCount(2),
+		WithRetryDelay(0),
+		WithClock(fake),
+	)
+
+	var history *Error
+	require.ErrorAs(t, err, &history)
+	require.Equal(t, []AttemptError{
+		{Attempt: 1, Time: start.Add(time.Second), Err: errs[0]},
+		{Attempt: 2, Time: start.Add(2 * time.Second), Err: errs[1]},
+		{Attempt: 3, Time: start.Add(3 * time.Second), Err: errs[2]},
+	}, history.Attempts)
+	for _, attemptErr := range errs {
+		require.ErrorIs(t, err, attemptErr)
+	}
+	require.Equal(t, errs[2], history.Last())
+}
+
+// retryAfterError ошибка с подсказкой о задержке
+type retryAfterError struct {
+	after time.Duration
+}
+
+func (e retryAfterError) Error() string { return "retry after " + e.after.String() }
+
+func (e retryAfterError) RetryAfter() time.Duration { return e.after }
+
+func TestDoRetryAfter(t *testing.T) {
+	grpcRetryInfo, err := status.New(codes.Unavailable, "unavailable").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(3 * time.Minute)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		inputErr           error
+		inputMaxRetryDelay time.Duration
+		wantAttemptOffsets []time.Duration
+	}{
+		{
+			name:               "no_hint",
+			inputErr:           fmt.Errorf(""),
+			wantAttemptOffsets: []time.Duration{0, time.Minute, 2 * time.Minute},
+		},
+		{
+			name:               "retry_after_error",
+			inputErr:           fmt.Errorf("wrap: %w", retryAfterError{after: 5 * time.Minute}),
+			wantAttemptOffsets: []time.Duration{0, 5 * time.Minute, 10 * time.Minute},
+		},
+		{
+			name:               "retry_after_capped",
+			inputErr:           retryAfterError{after: time.Hour},
+			inputMaxRetryDelay: 2 * time.Minute,
+			wantAttemptOffsets: []time.Duration{0, 2 * time.Minute, 4 * time.Minute},
+		},
+		{
+			name:               "grpc_retry_info",
+			inputErr:           grpcRetryInfo.Err(),
+			wantAttemptOffsets: []time.Duration{0, 3 * time.Minute, 6 * time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			fake := clocktest.NewFake(start)
+
+			var attemptOffsets []time.Duration
+			done := make(chan error)
+			go func() {
+				done <- Do(
+					func() error {
+						attemptOffsets = append(attemptOffsets, fake.Now().Sub(start))
+						return test.inputErr
+					},
+					WithRetryCount(2),
+					WithRetryDelay(time.Minute),
+					WithMaxRetryDelay(test.inputMaxRetryDelay),
+					WithClock(fake),
+				)
+			}()
+
+			err := advanceUntilDone(fake, time.Minute, done)
+			require.ErrorIs(t, err, test.inputErr)
+			require.Equal(t, test.wantAttemptOffsets, attemptOffsets)
+		})
+	}
+}
+
+func TestDoAttemptTimeout(t *testing.T) {
+	tests := []struct {
+		name                string
+		inputParentTimeout  time.Duration
+		inputAttemptTimeout time.Duration
+		wantTimeouts        []bool
+		wantErr             error
+	}{
+		{
+			name:                "attempt_timeouts",
+			inputParentTimeout:  time.Minute,
+			inputAttemptTimeout: 10 * time.Millisecond,
+			wantTimeouts:        []bool{true, true, true},
+			wantErr:             ErrAttemptTimeout,
+		},
+		{
+			name:                "parent_deadline",
+			inputParentTimeout:  10 * time.Millisecond,
+			inputAttemptTimeout: time.Minute,
+			wantTimeouts:        []bool{false},
+			wantErr:             context.DeadlineExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), test.inputParentTimeout)
+			defer cancel()
+
+			var timeouts []bool
+			err := DoContext(
+				ctx,
+				func(attemptCtx context.Context) error {
+					<-attemptCtx.Done()
+					return attemptCtx.Err()
+				},
+				WithRetryCount(2),
+				WithRetryDelay(0),
+				WithAttemptTimeout(test.inputAttemptTimeout),
+				// таймаут попытки повторяется даже если классификатор против
+				WithRetryIf(func(error) bool { return false }),
+				WithFailCallback(func(_ uint, err error) {
+					timeouts = append(timeouts, errors.Is(err, ErrAttemptTimeout))
+				}),
+			)
+			require.ErrorIs(t, err, test.wantErr)
+			require.Equal(t, test.wantTimeouts, timeouts)
+		})
+	}
+}
+
+func TestDoLifecycleCallbacks(t *testing.T) {
+	errAttempt := fmt.Errorf("attempt")
+
+	tests := []struct {
+		name          string
+		inputFailures int
+		wantEvents    []string
+	}{
+		{
+			name:          "success_after_retry",
+			inputFailures: 1,
+			wantEvents: []string{
+				"before 1",
+				"fail 1",
+				"backoff 1 1ms",
+				"before 2",
+				"success 2",
+			},
+		},
+		{
+			name:          "give_up",
+			inputFailures: 10,
+			wantEvents: []string{
+				"before 1",
+				"fail 1",
+				"backoff 1 1ms",
+				"before 2",
+				"fail 2",
+				"backoff 2 2ms",
+				"before 3",
+				"fail 3",
+				"give_up 3",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []string
+			attempts := 0
+			err := Do(
+				func() error {
+					attempts++
+					if attempts <= test.inputFailures {
+						return errAttempt
+					}
+					return nil
+				},
+				WithRetryCount(2),
+				WithRetryDelay(time.Millisecond),
+				WithRetryDelayMutation(ExpMutateRetryDelay),
+				WithBeforeAttemptCallback(func(attempt uint) {
+					events = append(events, fmt.Sprintf("before %d", attempt))
+				}),
+				WithFailCallback(func(attempt uint, _ error) {
+					events = append(events, fmt.Sprintf("fail %d", attempt))
+				}),
+				WithBackoffCallback(func(attempt uint, err error, delay time.Duration) {
+					require.ErrorIs(t, err, errAttempt)
+					events = append(events, fmt.Sprintf("backoff %d %s", attempt, delay))
+				}),
+				WithSuccessCallback(func(attempts uint) {
+					events = append(events, fmt.Sprintf("success %d", attempts))
+				}),
+				WithGiveUpCallback(func(attempts uint, giveUpErr error) {
+					require.ErrorIs(t, giveUpErr, errAttempt)
+					events = append(events, fmt.Sprintf("give_up %d", attempts))
+				}),
+			)
+			require.Equal(t, test.inputFailures > 2, err != nil)
+			require.Equal(t, test.wantEvents, events)
+		})
+	}
+}